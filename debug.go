@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/albertrdixon/gearbox/logger"
+)
+
+// serveDebug serves the debug API on addr:
+//
+//	/debug/conflicts  route conflicts between namespaces and the frontends they rejected
+//	/debug/rejected   frontends rejected by the host policy
+func serveDebug(addr string, e *Engine) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/conflicts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, e.routes.Conflicts())
+	})
+	mux.HandleFunc("/debug/rejected", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, e.routes.Rejections())
+	})
+
+	logger.Infof("Serving debug API on %s", addr)
+	if er := http.ListenAndServe(addr, mux); er != nil {
+		logger.Errorf("Debug API stopped: %v", er)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if er := json.NewEncoder(w).Encode(v); er != nil {
+		logger.Warnf("Unable to write debug response: %v", er)
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"crypto/md5"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/cache"
 
 	"github.com/albertrdixon/gearbox/logger"
+	"github.com/albertrdixon/gearbox/util"
 	"github.com/cenkalti/backoff"
 
 	"golang.org/x/net/context"
@@ -17,11 +21,7 @@ import (
 	"github.com/timelinelabs/romulus/loadbalancer"
 )
 
-func NewEngine(kubeapi, user, pass string, insecure bool, lb loadbalancer.LoadBalancer, timeout time.Duration, ctx context.Context) (*Engine, error) {
-	kc, er := kubernetes.NewClient(kubeapi, user, pass, insecure)
-	if er != nil {
-		return nil, er
-	}
+func NewEngine(source kubernetes.Source, lb loadbalancer.LoadBalancer, timeout time.Duration, ctx context.Context) *Engine {
 	back := backoff.NewExponentialBackOff()
 	back.MaxElapsedTime = timeout
 	return &Engine{
@@ -29,34 +29,43 @@ func NewEngine(kubeapi, user, pass string, insecure bool, lb loadbalancer.LoadBa
 		BackOff:      back,
 		LoadBalancer: lb,
 		Cache:        kubernetes.NewCache(),
-		Client:       kc,
-	}, nil
+		source:       source,
+		refresh:      make(map[string]*time.Timer),
+		rendered:     make(map[string]string),
+		frontends:    make(map[string][]string),
+		routes:       kubernetes.NewRouteIndex(),
+	}
 }
 
 func (e *Engine) Start(selector kubernetes.Selector, resync time.Duration) error {
-	var (
-		er error
-	)
-	if er = kubernetes.Status(e.Client); er != nil {
-		return fmt.Errorf("Failed to connect to kubernetes: %v", er)
+	if er := e.source.Status(); er != nil {
+		return fmt.Errorf("Failed to start %s source: %v", e.source.Kind(), er)
 	}
-	if er = e.LoadBalancer.Status(); er != nil {
+	if er := e.LoadBalancer.Status(); er != nil {
 		return fmt.Errorf("Failed to connect to loadbalancer: %v", er)
 	}
 
 	e.Lock()
 	defer e.Unlock()
 
-	createObjectCache(e, selector, resync)
-	time.Sleep(200 * time.Millisecond)
-	return createKubernetesCallbacks(e, selector, resync)
+	loadFrontends(e)
+	return e.source.Run(e, e.Cache, selector, resync, e.Context)
 }
 
 func (e *Engine) Add(obj interface{}) {
 	e.Lock()
 	defer e.Unlock()
 
-	resources, er := kubernetes.GenResources(e.Cache, e.Client, obj)
+	if pod, ok := obj.(*api.Pod); ok {
+		e.Cache.PodAdded(pod)
+		if kubernetes.PodRoutes {
+			renderPodGroups(e, kubernetes.PodGroups(pod)...)
+		}
+		return
+	}
+
+	e.Cache.ObjectAdded(obj)
+	resources, er := kubernetes.GenResources(e.Cache, e.source.Client(), obj)
 	if er != nil {
 		logger.Errorf(er.Error())
 	}
@@ -64,13 +73,24 @@ func (e *Engine) Add(obj interface{}) {
 	if er := addResources(e, resources); er != nil {
 		logger.Errorf(er.Error())
 	}
+	scheduleRefresh(e, resources)
+	addDependentResources(e, obj)
 }
 
 func (e *Engine) Delete(obj interface{}) {
 	e.Lock()
 	defer e.Unlock()
 
-	resources, er := kubernetes.GenResources(e.Cache, e.Client, obj)
+	if pod, ok := obj.(*api.Pod); ok {
+		e.Cache.PodDeleted(pod)
+		if kubernetes.PodRoutes {
+			renderPodGroups(e, kubernetes.PodGroups(pod)...)
+		}
+		return
+	}
+
+	e.Cache.ObjectDeleted(obj)
+	resources, er := kubernetes.GenResources(e.Cache, e.source.Client(), obj)
 	if er != nil {
 		logger.Errorf(er.Error())
 	}
@@ -81,21 +101,40 @@ func (e *Engine) Delete(obj interface{}) {
 	if er := deleteResources(e, resources); er != nil {
 		logger.Errorf(er.Error())
 	}
-
+	addDependentResources(e, obj)
 }
 
 func (e *Engine) Update(old, next interface{}) {
+	if kubernetes.SameNode(old, next) || kubernetes.SameNamespace(old, next) || kubernetes.SameConfigMap(old, next) {
+		return
+	}
+
 	e.Lock()
 	defer e.Unlock()
 
+	if pod, ok := next.(*api.Pod); ok {
+		e.Cache.PodAdded(pod)
+		if kubernetes.PodRoutes {
+			renderPodGroups(e, kubernetes.PodGroups(old, next)...)
+		}
+		if kubernetes.PodWeightChanged(old, next) {
+			for _, key := range e.Cache.PodServices(pod) {
+				namespace, name, _ := cache.SplitMetaNamespaceKey(string(key))
+				renderService(e, namespace, name)
+			}
+		}
+		return
+	}
+
+	e.Cache.ObjectAdded(next)
 	logger.Debugf("Gather resources from previous object")
-	oldResources, er := kubernetes.GenResources(e.Cache, e.Client, old)
+	oldResources, er := kubernetes.GenResources(e.Cache, e.source.Client(), old)
 	if er != nil {
 		logger.Errorf(er.Error())
 	}
 
 	logger.Debugf("Gather resources from new object")
-	newResources, er := kubernetes.GenResources(e.Cache, e.Client, next)
+	newResources, er := kubernetes.GenResources(e.Cache, e.source.Client(), next)
 	if er != nil {
 		logger.Errorf(er.Error())
 	}
@@ -103,6 +142,8 @@ func (e *Engine) Update(old, next interface{}) {
 	if er := updateResources(e, newResources, oldResources); er != nil {
 		logger.Errorf(er.Error())
 	}
+	scheduleRefresh(e, newResources)
+	addDependentResources(e, next)
 }
 
 func (e *Engine) Commit(fn UpsertFunc) error {
@@ -142,34 +183,110 @@ func updateResources(e *Engine, resources, previous kubernetes.ResourceList) err
 }
 
 func deleteResources(e *Engine, resources kubernetes.ResourceList) error {
+	released := kubernetes.ResourceList{}
+	defer func() { rerender(e, released) }()
+
 	for _, rsc := range resources {
 		backend, er := e.NewBackend(rsc)
 		if er != nil {
 			return er
 		}
-		frontend, er := e.NewFrontend(rsc)
-		if er != nil {
-			return er
+		upserted, tracked := e.frontends[rsc.ID()]
+		frontends := make([]loadbalancer.Frontend, 0, 1)
+		for _, fr := range rsc.Frontends() {
+			if tracked && !contains(upserted, fr.FrontendID()) {
+				continue
+			}
+			frontend, er := e.NewFrontend(fr)
+			if er != nil {
+				return er
+			}
+			frontends = append(frontends, frontend)
 		}
+		stale := staleFrontends(e, rsc.ID(), frontends)
 
 		fn := func() error {
-			logger.Infof("Removing %v", frontend)
-			if er := e.DeleteFrontend(frontend); er != nil {
-				return er
+			for _, frontend := range frontends {
+				logger.Infof("Removing %v", frontend)
+				if er := e.DeleteFrontend(frontend); er != nil {
+					return er
+				}
 			}
+			deleteFrontends(e, stale)
 			logger.Infof("Removing %v", backend)
 			return e.DeleteBackend(backend)
 		}
 		if er := e.Commit(fn); er != nil {
 			return er
 		}
+		delete(e.rendered, rsc.ID())
+		delete(e.frontends, rsc.ID())
+		released = append(released, e.routes.Release(rsc.ID())...)
 	}
 	return nil
 }
 
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFrontends fills the frontends upserted for each resource from the loadbalancer, so frontends
+// left over from before a restart are found by staleFrontends
+func loadFrontends(e *Engine) {
+	ids, er := e.ListFrontends()
+	if er != nil {
+		logger.Warnf("Unable to list frontends, stale ones from before a restart will stay: %v", er)
+		return
+	}
+	for _, id := range ids {
+		rid := kubernetes.FrontendResourceID(id)
+		e.frontends[rid] = append(e.frontends[rid], id)
+	}
+}
+
+// staleFrontends returns the IDs of frontends upserted for a resource that are not in frontends,
+// e.g. alternative routes that were removed
+func staleFrontends(e *Engine, id string, frontends []loadbalancer.Frontend) []string {
+	current := make(map[string]bool, len(frontends))
+	for _, fr := range frontends {
+		current[fr.GetID()] = true
+	}
+	stale := make([]string, 0, 1)
+	for _, fid := range e.frontends[id] {
+		if !current[fid] {
+			stale = append(stale, fid)
+		}
+	}
+	return stale
+}
+
+func deleteFrontends(e *Engine, ids []string) {
+	for _, id := range ids {
+		frontend, er := e.GetFrontend(id)
+		if er != nil {
+			logger.Debugf("[%v] Unable to look up stale frontend: %v", id, er)
+			continue
+		}
+		logger.Infof("Removing %v", frontend)
+		if er := e.DeleteFrontend(frontend); er != nil {
+			logger.Warnf("[%v] Unable to remove stale frontend: %v", id, er)
+		}
+	}
+}
+
 func addResources(e *Engine, resources kubernetes.ResourceList) error {
 	backends := make([]loadbalancer.Backend, 0, len(resources))
 	frontends := make([]loadbalancer.Frontend, 0, len(resources))
+	hashes := make(map[string]string, len(resources))
+	ids := make(map[string][]string, len(resources))
+	stale := make([]string, 0, 1)
+	changed := kubernetes.ResourceList{}
+	defer func() { rerender(e, changed) }()
 	for _, rsc := range resources {
 		logger.Debugf("[%v] Build Frontends and Backends", rsc.ID())
 		backend, er := e.NewBackend(rsc)
@@ -184,24 +301,53 @@ func addResources(e *Engine, resources kubernetes.ResourceList) error {
 			backend.AddServer(srvs[i])
 		}
 		logger.Debugf("[%v] Created new object: %v", rsc.ID(), backend)
-		backends = append(backends, backend)
 
-		frontend, er := e.NewFrontend(rsc)
-		if er != nil {
-			return er
+		fronts := make([]loadbalancer.Frontend, 0, 1)
+		allMids := make([]loadbalancer.Middleware, 0, 1)
+		permitted, rejected := e.routes.Enforce(e.HostPolicy(), rsc)
+		reportRejections(e, rejected)
+		allowed, found, others := e.routes.Check(rsc.ID(), permitted)
+		reportConflicts(e, found)
+		changed = append(changed, others...)
+		for _, fr := range allowed {
+			frontend, er := e.NewFrontend(fr)
+			if unsupported, ok := er.(*loadbalancer.UnsupportedRouteError); ok {
+				reportUnsupported(e, fr, unsupported)
+				continue
+			}
+			if er != nil {
+				return er
+			}
+			mids, er := e.NewMiddlewares(fr)
+			if er != nil {
+				return er
+			}
+			for i := range mids {
+				frontend.AddMiddleware(mids[i])
+			}
+			fronts = append(fronts, frontend)
+			allMids = append(allMids, mids...)
 		}
-		mids, er := e.NewMiddlewares(rsc)
-		if er != nil {
-			return er
+
+		hash := renderedHash(backend, srvs, fronts, allMids)
+		if hash != "" && e.rendered[rsc.ID()] == hash {
+			logger.Debugf("[%v] Unchanged since last upsert, skipping", rsc.ID())
+			continue
 		}
-		for i := range mids {
-			frontend.AddMiddleware(mids[i])
+		hashes[rsc.ID()] = hash
+		backends = append(backends, backend)
+		frontends = append(frontends, fronts...)
+		stale = append(stale, staleFrontends(e, rsc.ID(), fronts)...)
+		for _, frontend := range fronts {
+			ids[rsc.ID()] = append(ids[rsc.ID()], frontend.GetID())
+			logger.Debugf("[%v] Created new object: %v", rsc.ID(), frontend)
 		}
-		frontends = append(frontends, frontend)
-		logger.Debugf("[%v] Created new object: %v", rsc.ID(), frontend)
+	}
+	if len(backends) < 1 {
+		return nil
 	}
 
-	return e.Commit(func() error {
+	er := e.Commit(func() error {
 		for _, backend := range backends {
 			logger.Infof("Upserting %v", backend)
 			if er := e.UpsertBackend(backend); er != nil {
@@ -214,52 +360,170 @@ func addResources(e *Engine, resources kubernetes.ResourceList) error {
 				return er
 			}
 		}
+		deleteFrontends(e, stale)
 		return nil
 	})
+	if er != nil {
+		for id := range hashes {
+			delete(e.rendered, id)
+		}
+		return er
+	}
+	for id, hash := range hashes {
+		e.rendered[id] = hash
+		e.frontends[id] = ids[id]
+	}
+	return nil
 }
 
-func createObjectCache(e *Engine, selector kubernetes.Selector, resync time.Duration) {
-	var (
-		uc = e.GetUnversionedClient()
-		ec = e.GetExtensionsClient()
-	)
+// renderedHash returns a stable hash of the provider objects rendered for a resource, or "" if
+// they can not be serialized. Objects are hashed with their IDs as not all of them export them.
+func renderedHash(backend loadbalancer.Backend, srvs []loadbalancer.Server, frontends []loadbalancer.Frontend, mids []loadbalancer.Middleware) string {
+	objs := make([]loadbalancer.LoadbalancerObject, 0, len(srvs)+len(frontends)+len(mids)+1)
+	objs = append(objs, backend)
+	for i := range frontends {
+		objs = append(objs, frontends[i])
+	}
+	for i := range srvs {
+		objs = append(objs, srvs[i])
+	}
+	for i := range mids {
+		objs = append(objs, mids[i])
+	}
 
-	logger.Infof("Creating kubernetes object cache")
+	parts := make([]interface{}, 0, 2*len(objs))
+	for _, obj := range objs {
+		b, er := json.Marshal(obj)
+		if er != nil {
+			logger.Debugf("Unable to hash %v: %v", obj, er)
+			return ""
+		}
+		parts = append(parts, obj.GetID(), string(b))
+	}
+	return util.Hashf(md5.New(), parts...)
+}
 
-	service, er := kubernetes.CreateStore(kubernetes.ServicesKind, uc, selector, resync, e.Context)
+// addDependentResources re-renders the resources of Services splitting traffic onto obj, or
+// of NodePort Services when obj is a Node, of the Services in a Namespace, or of all Services
+// when obj is the ConfigMap
+func addDependentResources(e *Engine, obj interface{}) {
+	resources, er := kubernetes.GenDependentResources(e.Cache, e.source.Client(), obj)
 	if er != nil {
-		logger.Warnf("Failed to create Service cache")
+		logger.Errorf(er.Error())
 	}
-	endpoints, er := kubernetes.CreateStore(kubernetes.EndpointsKind, uc, selector, resync, e.Context)
-	if er != nil {
-		logger.Warnf("Failed to create Endpoints cache")
+	if len(resources) < 1 {
+		return
 	}
-	ingress, er := kubernetes.CreateStore(kubernetes.IngressesKind, ec, selector, resync, e.Context)
-	if er != nil {
-		logger.Warnf("Failed to create Ingress cache")
+	if er := addResources(e, resources); er != nil {
+		logger.Errorf(er.Error())
+	}
+	scheduleRefresh(e, resources)
+}
+
+// renderPodGroups upserts the resources of pod groups and removes those of empty groups
+func renderPodGroups(e *Engine, groups ...string) {
+	if len(groups) < 1 {
+		return
+	}
+	resources, removals := kubernetes.GenPodResources(e.Cache, e.source.Client(), groups...)
+	if er := addResources(e, resources); er != nil {
+		logger.Errorf(er.Error())
+	}
+	if er := deleteResources(e, removals); er != nil {
+		logger.Errorf(er.Error())
 	}
+	scheduleRefresh(e, resources)
+	scheduleRefresh(e, removals)
+}
 
-	e.SetIngressStore(ingress)
-	e.SetServiceStore(service)
-	e.SetEndpointsStore(endpoints)
+// reportConflicts logs route conflicts and records them as Events of the Services involved
+func reportConflicts(e *Engine, conflicts []*kubernetes.Conflict) {
+	for _, c := range conflicts {
+		logger.Warnf("%v", c)
+		kubernetes.RecordConflict(e.source.Client(), c)
+	}
 }
 
-func createKubernetesCallbacks(e *Engine, selector kubernetes.Selector, resync time.Duration) error {
-	var (
-		uc = e.GetUnversionedClient()
-		ec = e.GetExtensionsClient()
-	)
+// reportRejections logs frontends rejected by the host policy and records them as Events of
+// their Services, so the owners learn about it
+func reportRejections(e *Engine, rejections []*kubernetes.Rejection) {
+	for _, r := range rejections {
+		logger.Warnf("%v", r)
+		kubernetes.RecordRejection(e.source.Client(), r)
+	}
+}
 
-	logger.Infof("Starting kubernetes watchers")
+// reportUnsupported logs frontends the loadbalancer can not match and records them as Events of
+// their Services, like rejections
+func reportUnsupported(e *Engine, rsc *kubernetes.Resource, er *loadbalancer.UnsupportedRouteError) {
+	logger.Warnf("%v", er)
+	kubernetes.RecordUnsupportedRoute(e.source.Client(), rsc, er.Error())
+}
 
-	_, endpoint := kubernetes.CreateFullController(kubernetes.EndpointsKind, e, uc, selector, resync)
-	_, service := kubernetes.CreateFullController(kubernetes.ServicesKind, e, uc, selector, resync)
-	_, ingress := kubernetes.CreateFullController(kubernetes.IngressesKind, e, ec, selector, resync)
+// rerender renders resources again from their Service or pod group, e.g. after their
+// frontends won or lost a route conflict
+func rerender(e *Engine, resources kubernetes.ResourceList) {
+	for _, rsc := range resources {
+		if group := rsc.PodGroup(); group != "" {
+			renderPodGroups(e, group)
+			continue
+		}
+		namespace, name := rsc.Service()
+		renderService(e, namespace, name)
+	}
+}
 
-	go endpoint.Run(e.Done())
-	go service.Run(e.Done())
-	go ingress.Run(e.Done())
-	return nil
+// renderService renders the resources of a Service again
+func renderService(e *Engine, namespace, name string) {
+	svc, er := e.GetService(e.source.Client(), namespace, name)
+	if er != nil {
+		logger.Warnf("Unable to re-render Service(%s/%s): %v", namespace, name, er)
+		return
+	}
+	list, er := kubernetes.GenResources(e.Cache, e.source.Client(), svc)
+	if er != nil {
+		logger.Warnf("Unable to re-render Service(%s/%s): %v", namespace, name, er)
+		return
+	}
+	if er := addResources(e, list); er != nil {
+		logger.Errorf(er.Error())
+	}
+	scheduleRefresh(e, list)
+}
+
+// scheduleRefresh re-renders resources from their Service or pod group when they ask for it,
+// e.g. once the drain period of their draining servers is over so that the servers get removed.
+func scheduleRefresh(e *Engine, resources kubernetes.ResourceList) {
+	for _, rsc := range resources {
+		id := rsc.ID()
+		if t, ok := e.refresh[id]; ok {
+			t.Stop()
+			delete(e.refresh, id)
+		}
+
+		wait := rsc.RefreshIn()
+		if wait <= 0 {
+			continue
+		}
+		logger.Debugf("[%v] Re-render in %v", id, wait)
+		if group := rsc.PodGroup(); group != "" {
+			e.refresh[id] = time.AfterFunc(wait, func() {
+				e.Lock()
+				defer e.Unlock()
+				renderPodGroups(e, group)
+			})
+			continue
+		}
+		namespace, name := rsc.Service()
+		e.refresh[id] = time.AfterFunc(wait, func() {
+			svc, er := e.GetService(e.source.Client(), namespace, name)
+			if er != nil {
+				logger.Warnf("[%v] Unable to re-render: %v", id, er)
+				return
+			}
+			e.Add(svc)
+		})
+	}
 }
 
 type Engine struct {
@@ -268,7 +532,15 @@ type Engine struct {
 	context.Context
 	loadbalancer.LoadBalancer
 	*kubernetes.Cache
-	*kubernetes.Client
+
+	source   kubernetes.Source
+	refresh  map[string]*time.Timer
+	rendered map[string]string
+
+	// frontends holds the IDs of the frontends last upserted for each resource
+	frontends map[string][]string
+	// routes indexes the frontends of all resources to find conflicting routes
+	routes *kubernetes.RouteIndex
 }
 
 type UpsertFunc func() error
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/cache"
+
+	"github.com/timelinelabs/romulus/kubernetes"
+	"github.com/timelinelabs/romulus/loadbalancer"
+)
+
+type testSource struct{}
+
+func (testSource) Kind() string                   { return "test" }
+func (testSource) Status() error                  { return nil }
+func (testSource) Client() kubernetes.SuperClient { return nil }
+func (testSource) Run(kubernetes.Updater, *kubernetes.Cache, kubernetes.Selector, time.Duration, context.Context) error {
+	return nil
+}
+
+type testObject struct{ id string }
+
+func (o *testObject) GetID() string                         { return o.id }
+func (o *testObject) AddMiddleware(loadbalancer.Middleware) {}
+
+type testBackend struct {
+	testObject
+	servers []string
+}
+
+func (b *testBackend) AddServer(srv loadbalancer.Server) { b.servers = append(b.servers, srv.GetID()) }
+
+// testLB keeps the server IDs of upserted backends
+type testLB struct {
+	backends map[string][]string
+}
+
+func (l *testLB) NewFrontend(rsc *kubernetes.Resource) (loadbalancer.Frontend, error) {
+	return &testObject{rsc.FrontendID()}, nil
+}
+func (l *testLB) GetFrontend(id string) (loadbalancer.Frontend, error) { return &testObject{id}, nil }
+func (l *testLB) ListFrontends() ([]string, error)                     { return nil, nil }
+func (l *testLB) UpsertFrontend(loadbalancer.Frontend) error           { return nil }
+func (l *testLB) DeleteFrontend(loadbalancer.Frontend) error           { return nil }
+func (l *testLB) NewBackend(rsc *kubernetes.Resource) (loadbalancer.Backend, error) {
+	return &testBackend{testObject: testObject{rsc.ID()}}, nil
+}
+func (l *testLB) GetBackend(id string) (loadbalancer.Backend, error) {
+	return &testBackend{testObject: testObject{id}}, nil
+}
+func (l *testLB) UpsertBackend(ba loadbalancer.Backend) error {
+	b := ba.(*testBackend)
+	sort.Strings(b.servers)
+	l.backends[b.GetID()] = b.servers
+	return nil
+}
+func (l *testLB) DeleteBackend(ba loadbalancer.Backend) error {
+	delete(l.backends, ba.GetID())
+	return nil
+}
+func (l *testLB) NewServers(rsc *kubernetes.Resource) ([]loadbalancer.Server, error) {
+	list := make([]loadbalancer.Server, 0, len(rsc.Servers()))
+	for _, s := range rsc.Servers() {
+		list = append(list, &testObject{s.ID()})
+	}
+	return list, nil
+}
+func (l *testLB) GetServers(string) ([]loadbalancer.Server, error)             { return nil, nil }
+func (l *testLB) UpsertServer(loadbalancer.Backend, loadbalancer.Server) error { return nil }
+func (l *testLB) DeleteServer(loadbalancer.Backend, loadbalancer.Server) error { return nil }
+func (l *testLB) NewMiddlewares(*kubernetes.Resource) ([]loadbalancer.Middleware, error) {
+	return nil, nil
+}
+func (l *testLB) Kind() string  { return "test" }
+func (l *testLB) Status() error { return nil }
+
+func testNode(name, ip string) *api.Node {
+	return &api.Node{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Status: api.NodeStatus{
+			Addresses:  []api.NodeAddress{{Type: api.NodeInternalIP, Address: ip}},
+			Conditions: []api.NodeCondition{{Type: api.NodeReady, Status: api.ConditionTrue}},
+		},
+	}
+}
+
+func TestEngineNodeEvents(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		lb    = &testLB{backends: map[string][]string{}}
+		e     = NewEngine(testSource{}, lb, time.Second, context.Background())
+		nodes = cache.NewStore(cache.MetaNamespaceKeyFunc)
+		svcs  = cache.NewStore(cache.MetaNamespaceKeyFunc)
+		svc   = &api.Service{
+			ObjectMeta: api.ObjectMeta{Name: "web", Namespace: "test", Annotations: map[string]string{"romulus/backend_mode": "nodeport"}},
+			Spec: api.ServiceSpec{
+				Type:  api.ServiceTypeNodePort,
+				Ports: []api.ServicePort{{Name: "web", Port: 80, NodePort: 30080}},
+			},
+		}
+		node1, node2 = testNode("node-1", "192.168.0.1"), testNode("node-2", "192.168.0.2")
+	)
+
+	defer func(k string) { kubernetes.Keyspace = k }(kubernetes.Keyspace)
+	kubernetes.Keyspace = "romulus"
+	e.SetNodeStore(nodes)
+	e.SetServiceStore(svcs)
+	nodes.Add(node1)
+	svcs.Add(svc)
+	e.Add(svc)
+	is.Len(lb.backends["test.web.web"], 1)
+
+	// events arrive before the store of the Node watch catches up
+	e.Add(node2)
+	is.Len(lb.backends["test.web.web"], 2, "an added Node should get a server")
+
+	e.Delete(node2)
+	if is.Len(lb.backends["test.web.web"], 1, "a deleted Node should lose its server") {
+		is.Contains(lb.backends["test.web.web"][0], "node-1")
+	}
+
+	notReady := testNode("node-1", "192.168.0.1")
+	notReady.Status.Conditions[0].Status = api.ConditionFalse
+	e.Update(node1, notReady)
+	is.Empty(lb.backends["test.web.web"], "a NotReady Node should lose its server")
+}
@@ -0,0 +1,33 @@
+package kubernetes
+
+import (
+	"strconv"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// DefaultStickyCookie is the session cookie name used when none is given
+const DefaultStickyCookie = "romulus_session"
+
+// IsSticky returns true if requests of a client should stick to one server, either from the
+// sticky annotation or from the sessionAffinity of the Service. The annotation wins.
+func (r *Resource) IsSticky() bool {
+	if val, ok := r.GetAnnotation(StickyKey); ok {
+		if b, er := strconv.ParseBool(val); er == nil {
+			return b
+		}
+	}
+	return r.affinity
+}
+
+// StickyCookie returns the name of the session cookie for sticky Resources
+func (r *Resource) StickyCookie() string {
+	if val, ok := r.GetAnnotation(StickyCookieKey); ok && val != "" {
+		return val
+	}
+	return DefaultStickyCookie
+}
+
+func hasSessionAffinity(svc *api.Service) bool {
+	return svc.Spec.SessionAffinity == api.ServiceAffinityClientIP
+}
@@ -0,0 +1,36 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func TestSticky(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		c     = NewCache()
+		tests = []struct {
+			affinity    api.ServiceAffinity
+			annotations map[string]string
+			sticky      bool
+			cookie      string
+		}{
+			{api.ServiceAffinityNone, nil, false, DefaultStickyCookie},
+			{api.ServiceAffinityClientIP, nil, true, DefaultStickyCookie},
+			{api.ServiceAffinityNone, map[string]string{"romulus/sticky": "true", "romulus/sticky_cookie": "sid"}, true, "sid"},
+			{api.ServiceAffinityClientIP, map[string]string{"romulus/sticky": "false"}, false, DefaultStickyCookie},
+		}
+	)
+
+	for _, t := range tests {
+		svc := testService("app", t.annotations)
+		svc.Spec.SessionAffinity = t.affinity
+		list, er := GenResources(c, nil, svc)
+		if is.NoError(er) && is.Len(list, 1) {
+			is.Equal(t.sticky, list[0].IsSticky(), "%v %v", t.affinity, t.annotations)
+			is.Equal(t.cookie, list[0].StickyCookie())
+		}
+	}
+}
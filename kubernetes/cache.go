@@ -3,6 +3,8 @@ package kubernetes
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/albertrdixon/gearbox/logger"
 
@@ -10,14 +12,51 @@ import (
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/client/cache"
 	"k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/intstr"
 )
 
 func NewCache() *Cache {
 	return &Cache{
-		ingress:   cache.NewStore(cache.MetaNamespaceKeyFunc),
-		service:   cache.NewStore(cache.MetaNamespaceKeyFunc),
-		endpoints: cache.NewStore(cache.MetaNamespaceKeyFunc),
-		ingMap:    make(map[cache.ExplicitKey]cache.ExplicitKey),
+		ingress:    cache.NewStore(cache.MetaNamespaceKeyFunc),
+		service:    cache.NewStore(cache.MetaNamespaceKeyFunc),
+		endpoints:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		pod:        cache.NewStore(cache.MetaNamespaceKeyFunc),
+		node:       cache.NewStore(cache.MetaNamespaceKeyFunc),
+		namespace:  cache.NewStore(cache.MetaNamespaceKeyFunc),
+		config:     cache.NewStore(cache.MetaNamespaceKeyFunc),
+		ingMap:     make(map[cache.ExplicitKey]cache.ExplicitKey),
+		splitMap:   make(map[cache.ExplicitKey]map[cache.ExplicitKey]bool),
+		podGroups:  make(map[string]map[string]bool),
+		podTargets: make(map[cache.ExplicitKey]map[cache.ExplicitKey]bool),
+		selector:   labels.Everything(),
+		known:      make(map[string]map[string]*Server),
+		drains:     make(map[string]time.Time),
+	}
+}
+
+// ObjectAdded records a new or updated object ahead of its store, like PodAdded, for objects whose
+// events render the Services depending on them from the store
+func (k *Cache) ObjectAdded(obj interface{}) {
+	switch t := obj.(type) {
+	case *api.Node:
+		k.node.Add(t)
+	case *api.Namespace:
+		k.namespace.Add(t)
+	case *extensions.ConfigMap:
+		k.config.Add(t)
+	}
+}
+
+// ObjectDeleted forgets an object ahead of its store, see ObjectAdded
+func (k *Cache) ObjectDeleted(obj interface{}) {
+	switch t := obj.(type) {
+	case *api.Node:
+		k.node.Delete(t)
+	case *api.Namespace:
+		k.namespace.Delete(t)
+	case *extensions.ConfigMap:
+		k.config.Delete(t)
 	}
 }
 
@@ -33,10 +72,24 @@ func (k *Cache) SetEndpointsStore(store cache.Store) {
 	k.endpoints = store
 }
 
+func (k *Cache) SetPodStore(store cache.Store) {
+	k.pod = store
+}
+
+func (k *Cache) SetNodeStore(store cache.Store) {
+	k.node = store
+}
+
 func (k *Cache) MapServiceToIngress(namespace, serviceName, ingressName string) {
+	k.mapServiceToIngress(namespace, serviceName, namespace, ingressName)
+}
+
+// mapServiceToIngress maps a Service to an Ingress that may live in another namespace, so that
+// changes to the Service or its Endpoints regenerate the routes of the Ingress
+func (k *Cache) mapServiceToIngress(svcNamespace, serviceName, ingNamespace, ingressName string) {
 	var (
-		svcKey = cacheLookupKey(namespace, serviceName)
-		ingKey = cacheLookupKey(namespace, ingressName)
+		svcKey = cacheLookupKey(svcNamespace, serviceName)
+		ingKey = cacheLookupKey(ingNamespace, ingressName)
 	)
 
 	logger.Debugf("Mapping Service(%q) -> Ingress(%q)", svcKey, ingKey)
@@ -46,6 +99,19 @@ func (k *Cache) MapServiceToIngress(namespace, serviceName, ingressName string)
 func (k *Cache) ServiceDeleted(namespace, name string) {
 	var key = cacheLookupKey(namespace, name)
 	delete(k.ingMap, key)
+	for _, owners := range k.splitMap {
+		delete(owners, key)
+	}
+
+	prefix := GenResourceID(namespace, name, intstr.FromString(""))
+	for id, servers := range k.known {
+		if strings.HasPrefix(id, prefix) {
+			for sid := range servers {
+				delete(k.drains, drainKey(id, sid))
+			}
+			delete(k.known, id)
+		}
+	}
 }
 
 func (k *Cache) GetEndpoints(client unversioned.Interface, namespace, name string) (*api.Endpoints, error) {
@@ -59,6 +125,9 @@ func (k *Cache) GetEndpoints(client unversioned.Interface, namespace, name strin
 		return nil, er
 	}
 	if !ok {
+		if client == nil {
+			return nil, fmt.Errorf("Could not find Endpoints %q", key)
+		}
 		logger.Debugf("Looking up Endpoints(%q) on server", key)
 		if en, er := client.Endpoints(namespace).Get(name); er == nil {
 			k.endpoints.Add(en)
@@ -87,6 +156,9 @@ func (k *Cache) GetService(client unversioned.Interface, namespace, name string)
 		return nil, er
 	}
 	if !ok {
+		if client == nil {
+			return nil, fmt.Errorf("Could not find Service %q", key)
+		}
 		logger.Debugf("Looking up Service(%q) on server", key)
 		if sv, er := client.Services(namespace).Get(name); er == nil {
 			k.service.Add(sv)
@@ -103,6 +175,35 @@ func (k *Cache) GetService(client unversioned.Interface, namespace, name string)
 	return s, nil
 }
 
+func (k *Cache) GetPod(client unversioned.Interface, namespace, name string) (*api.Pod, error) {
+	var (
+		key = cacheLookupKey(namespace, name)
+	)
+
+	logger.Debugf("Looking up Pod(%q) in cache", key)
+	obj, ok, er := k.pod.Get(key)
+	if er != nil {
+		return nil, er
+	}
+	if !ok {
+		if client == nil {
+			return nil, fmt.Errorf("Could not find Pod %q", key)
+		}
+		logger.Debugf("Looking up Pod(%q) on server", key)
+		if po, er := client.Pods(namespace).Get(name); er == nil {
+			k.pod.Add(po)
+			return po, nil
+		}
+		return nil, fmt.Errorf("Could not find Pod %q", key)
+	}
+
+	p, ok := obj.(*api.Pod)
+	if !ok {
+		return nil, errors.New("Pod cache returned non-Pod object")
+	}
+	return p, nil
+}
+
 func (k *Cache) GetIngress(client unversioned.ExtensionsInterface, namespace, name string) (*extensions.Ingress, error) {
 	var (
 		sk      = cacheLookupKey(namespace, name)
@@ -119,8 +220,12 @@ func (k *Cache) GetIngress(client unversioned.ExtensionsInterface, namespace, na
 		return nil, er
 	}
 	if !ok {
+		if client == nil {
+			return nil, fmt.Errorf("Could not find Ingress %q", key)
+		}
 		logger.Debugf("Looking up Ingress(%q) on server", key)
-		if in, er := client.Ingress(namespace).Get(name); er == nil {
+		ns, name, _ := cache.SplitMetaNamespaceKey(string(key))
+		if in, er := client.Ingress(ns).Get(name); er == nil {
 			k.ingress.Add(in)
 			return in, nil
 		}
@@ -0,0 +1,155 @@
+package kubernetes
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"golang.org/x/net/context"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/controller/framework"
+	"k8s.io/kubernetes/pkg/fields"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+const (
+	// DefaultPrefix marks ConfigMap keys holding annotation defaults, e.g. default.pass_host_header
+	DefaultPrefix = "default."
+	// OverridePrefix marks ConfigMap keys holding annotations that override every object, e.g. override.redirect_to_ssl
+	OverridePrefix = "override."
+)
+
+// ParseConfigMap splits a "namespace/name" ConfigMap reference
+func ParseConfigMap(ref string) (string, string, error) {
+	namespace, name, er := cache.SplitMetaNamespaceKey(ref)
+	if er != nil || name == "" {
+		return "", "", fmt.Errorf("Invalid ConfigMap %q, must be 'namespace/name'", ref)
+	}
+	if namespace == "" {
+		namespace = api.NamespaceDefault
+	}
+	return namespace, name, nil
+}
+
+// CreateConfigMapStore watches the single ConfigMap namespace/name
+func CreateConfigMapStore(c cache.Getter, namespace, name string, resync time.Duration, ctx context.Context) cache.Store {
+	store := cache.NewStore(framework.DeletionHandlingMetaNamespaceKeyFunc)
+	lw := getConfigMapListWatch(c, namespace, name)
+	cache.NewReflector(lw, &extensions.ConfigMap{}, store, resync).RunUntil(ctx.Done())
+	return store
+}
+
+// CreateConfigMapController sends changes of the single ConfigMap namespace/name to the Updater
+func CreateConfigMapController(w Updater, c cache.Getter, namespace, name string, resync time.Duration) (cache.Store, *framework.Controller) {
+	handler := framework.ResourceEventHandlerFuncs{
+		AddFunc:    addDelete(Add, w),
+		DeleteFunc: addDelete(Delete, w),
+		UpdateFunc: update(Update, w),
+	}
+	return framework.NewInformer(getConfigMapListWatch(c, namespace, name), &extensions.ConfigMap{}, resync, handler)
+}
+
+func getConfigMapListWatch(getter cache.Getter, namespace, name string) *cache.ListWatch {
+	selector := fields.OneTermEqualSelector("metadata.name", name)
+	return &cache.ListWatch{
+		ListFunc: func(options api.ListOptions) (runtime.Object, error) {
+			logger.Debugf("Running ListFunc for ConfigMap(%q)", cacheLookupKey(namespace, name))
+			return getter.Get().Namespace(namespace).Resource(ConfigMapsKind).
+				LabelsSelectorParam(labels.Everything()).FieldsSelectorParam(selector).
+				Do().Get()
+		},
+		WatchFunc: func(options api.ListOptions) (watch.Interface, error) {
+			logger.Debugf("Running WatchFunc for ConfigMap(%q)", cacheLookupKey(namespace, name))
+			return getter.Get().Prefix("watch").Namespace(namespace).Resource(ConfigMapsKind).
+				LabelsSelectorParam(labels.Everything()).FieldsSelectorParam(selector).
+				Param("resourceVersion", options.ResourceVersion).Watch()
+		},
+	}
+}
+
+func (k *Cache) SetConfigStore(store cache.Store) {
+	k.config = store
+}
+
+// globalAnnotations returns the annotation defaults and overrides from the watched ConfigMap
+func (k *Cache) globalAnnotations() (map[string]string, map[string]string) {
+	for _, obj := range k.config.List() {
+		if cm, ok := obj.(*extensions.ConfigMap); ok {
+			return configAnnotations(cm)
+		}
+	}
+	return nil, nil
+}
+
+func configAnnotations(cm *extensions.ConfigMap) (map[string]string, map[string]string) {
+	var (
+		defaults  = make(map[string]string)
+		overrides = make(map[string]string)
+	)
+
+	for key, value := range cm.Data {
+		switch {
+		case strings.HasPrefix(key, DefaultPrefix):
+			defaults[path.Join(Keyspace, strings.TrimPrefix(key, DefaultPrefix))] = value
+		case strings.HasPrefix(key, OverridePrefix):
+			overrides[path.Join(Keyspace, strings.TrimPrefix(key, OverridePrefix))] = value
+		case key == HostPolicyKey:
+		default:
+			logger.Warnf("Ignoring key %q in ConfigMap(%q), must start with %q or %q", key, cm.GetName(), DefaultPrefix, OverridePrefix)
+		}
+	}
+	return defaults, overrides
+}
+
+// annotationsFor returns the annotations of an object in namespace merged, from lowest to highest
+// precedence, from the ConfigMap defaults, the Namespace defaults, the object and the ConfigMap overrides.
+func (k *Cache) annotationsFor(client unversioned.Interface, namespace string, anno map[string]string) map[string]string {
+	var (
+		defaults, overrides = k.globalAnnotations()
+		merged              = make(map[string]string, len(defaults)+len(anno)+len(overrides))
+	)
+
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range k.withNamespaceDefaults(client, namespace, anno) {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// configResources returns the Resources of all Services, as the ConfigMap applies to all of them
+func configResources(store *Cache, client SuperClient) ResourceList {
+	var list ResourceList = make([]*Resource, 0, 1)
+	for _, obj := range store.service.List() {
+		if svc, ok := obj.(*api.Service); ok {
+			list = append(list, resourcesFromService(store, client, svc)...)
+		}
+	}
+	return list
+}
+
+// SameConfigMap returns true if old and next are the same ConfigMap with the same data
+func SameConfigMap(old, next interface{}) bool {
+	a, ok := old.(*extensions.ConfigMap)
+	if !ok {
+		return false
+	}
+	b, ok := next.(*extensions.ConfigMap)
+	if !ok {
+		return false
+	}
+	return a.GetName() == b.GetName() && reflect.DeepEqual(a.Data, b.Data)
+}
@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+func TestConfigMapAnnotations(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		cm   = &extensions.ConfigMap{
+			ObjectMeta: api.ObjectMeta{Name: "romulus", Namespace: "kube-system"},
+			Data: map[string]string{
+				"default.read_timeout":       "5s",
+				"default.pass_host_header":   "false",
+				"override.redirect_to_ssl":   "true",
+				"override.web.write_timeout": "1m",
+				"bogus":                      "x",
+			},
+		}
+		ns = &api.Namespace{ObjectMeta: api.ObjectMeta{
+			Name:        "test",
+			Annotations: map[string]string{"romulus/pass_host_header": "true", "romulus/redirect_to_ssl": "false"},
+		}}
+		svc = testService("api", map[string]string{"romulus/read_timeout": "10s", "romulus/redirect_to_ssl": "false"})
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	c.config.Add(cm)
+	c.namespace.Add(ns)
+	c.service.Add(svc)
+
+	list, er := GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+	for key, expected := range map[string]string{
+		"read_timeout":     "10s",
+		"pass_host_header": "true",
+		"redirect_to_ssl":  "true",
+		"write_timeout":    "1m",
+	} {
+		val, _ := list[0].GetAnnotation(key)
+		is.Equal(expected, val, key)
+	}
+
+	deps, er := GenDependentResources(c, nil, cm)
+	must.NoError(er)
+	is.Len(deps, 1)
+
+	next := *cm
+	is.True(SameConfigMap(cm, &next))
+	next.Data = map[string]string{"default.read_timeout": "1s", "override.read_timeout": "2s"}
+	is.False(SameConfigMap(cm, &next))
+
+	c.ObjectAdded(&next)
+	deps, er = GenDependentResources(c, nil, &next)
+	must.NoError(er)
+	if is.Len(deps, 1) {
+		val, _ := deps[0].GetAnnotation("read_timeout")
+		is.Equal("2s", val, "the updated ConfigMap should apply right away")
+	}
+	c.ObjectDeleted(&next)
+	deps, er = GenDependentResources(c, nil, &next)
+	must.NoError(er)
+	if is.Len(deps, 1) {
+		val, _ := deps[0].GetAnnotation("read_timeout")
+		is.Equal("10s", val, "the deleted ConfigMap should not apply")
+	}
+
+	namespace, name, er := ParseConfigMap("romulus")
+	is.NoError(er)
+	is.Equal([]string{"default", "romulus"}, []string{namespace, name})
+	_, _, er = ParseConfigMap("a/b/c")
+	is.Error(er)
+}
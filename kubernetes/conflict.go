@@ -0,0 +1,402 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"github.com/bradfitz/slice"
+	"k8s.io/kubernetes/pkg/api"
+	kunversioned "k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+const (
+	// Conflict policies, deciding which of two routes of different namespaces keeps the requests
+	// they both claim
+	OldestWins        = "oldest"
+	NamespacePriority = "namespace-priority"
+	RejectBoth        = "reject-both"
+
+	// ExactConflict is two routes matching the same requests
+	ExactConflict = "exact"
+	// ShadowConflict is a route never matching because a route of equal or higher priority
+	// matches all of its requests
+	ShadowConflict = "shadow"
+)
+
+var (
+	// ConflictPolicy decides conflicts between routes of different namespaces
+	ConflictPolicy = OldestWins
+	// NamespacePriorities ranks namespaces for the namespace-priority policy, first wins.
+	// Unlisted namespaces come last.
+	NamespacePriorities = []string{}
+)
+
+// Conflict is a pair of frontends of different namespaces claiming the same requests
+type Conflict struct {
+	Kind      string    `json:"kind"`
+	Policy    string    `json:"policy"`
+	Frontends []string  `json:"frontends"`
+	Routes    []string  `json:"routes"`
+	Rejected  []string  `json:"rejected"`
+	Since     time.Time `json:"since"`
+
+	resources ResourceList
+}
+
+// RouteIndex holds the frontends of all rendered Resources to find conflicting routes, and
+// those rejected by the host policy
+type RouteIndex struct {
+	sync.RWMutex
+	claims     map[string]*Resource
+	conflicts  map[string]*Conflict
+	rejections map[string][]*Rejection
+}
+
+// NewRouteIndex returns an empty RouteIndex
+func NewRouteIndex() *RouteIndex {
+	return &RouteIndex{
+		claims:     make(map[string]*Resource),
+		conflicts:  make(map[string]*Conflict),
+		rejections: make(map[string][]*Rejection),
+	}
+}
+
+// Check indexes the frontends of Resource id and returns those that may be upserted, the conflicts
+// found for the first time and the Resources of other frontends that were rejected or allowed again
+// because of them, which need rendering again.
+func (x *RouteIndex) Check(id string, frontends ResourceList) (allowed ResourceList, found []*Conflict, changed ResourceList) {
+	x.Lock()
+	defer x.Unlock()
+
+	before := x.rejected()
+	old := x.drop(id)
+	for _, fr := range frontends {
+		x.claims[fr.FrontendID()] = fr
+	}
+	for _, fr := range frontends {
+		for _, other := range x.claims {
+			c := findConflict(fr, other)
+			if c == nil {
+				continue
+			}
+			key := conflictKey(c.Frontends)
+			if prev, ok := old[key]; ok {
+				c.Since = prev.Since
+			} else {
+				found = append(found, c)
+			}
+			x.conflicts[key] = c
+		}
+	}
+
+	after := x.rejected()
+	for _, fr := range frontends {
+		if !after[fr.FrontendID()] {
+			allowed = append(allowed, fr)
+		}
+	}
+	return allowed, found, x.changed(id, before, after)
+}
+
+// Release drops the frontends of a removed Resource and returns the Resources of the frontends
+// it had pushed out, which need rendering again.
+func (x *RouteIndex) Release(id string) ResourceList {
+	x.Lock()
+	defer x.Unlock()
+
+	before := x.rejected()
+	x.drop(id)
+	delete(x.rejections, id)
+	return x.changed(id, before, x.rejected())
+}
+
+// Conflicts returns the current conflicts, oldest first
+func (x *RouteIndex) Conflicts() []*Conflict {
+	x.RLock()
+	defer x.RUnlock()
+
+	list := make([]*Conflict, 0, len(x.conflicts))
+	for _, c := range x.conflicts {
+		list = append(list, c)
+	}
+	slice.Sort(list, func(i, j int) bool {
+		if list[i].Since.Equal(list[j].Since) {
+			return conflictKey(list[i].Frontends) < conflictKey(list[j].Frontends)
+		}
+		return list[i].Since.Before(list[j].Since)
+	})
+	return list
+}
+
+// drop removes the frontends of Resource id with their conflicts, which are returned by key
+func (x *RouteIndex) drop(id string) map[string]*Conflict {
+	old := make(map[string]*Conflict)
+	for fid, fr := range x.claims {
+		if fr.ID() == id {
+			delete(x.claims, fid)
+		}
+	}
+	for key, c := range x.conflicts {
+		for _, r := range c.resources {
+			if r.ID() == id {
+				old[key] = c
+				delete(x.conflicts, key)
+				break
+			}
+		}
+	}
+	return old
+}
+
+func (x *RouteIndex) rejected() map[string]bool {
+	rejected := make(map[string]bool)
+	for _, c := range x.conflicts {
+		for _, id := range c.Rejected {
+			rejected[id] = true
+		}
+	}
+	return rejected
+}
+
+// changed returns the Resources, other than id, with a frontend in only one of before and after
+func (x *RouteIndex) changed(id string, before, after map[string]bool) ResourceList {
+	var (
+		list = make(ResourceList, 0, 1)
+		seen = map[string]bool{id: true}
+	)
+	for _, set := range []map[string]bool{before, after} {
+		for fid := range set {
+			fr, ok := x.claims[fid]
+			if !ok || before[fid] == after[fid] || seen[fr.ID()] {
+				continue
+			}
+			seen[fr.ID()] = true
+			list = append(list, fr)
+		}
+	}
+	return list
+}
+
+// String describes the conflict for logs and Events
+func (c *Conflict) String() string {
+	return fmt.Sprintf("%s route conflict between %s %v and %s %v, %s policy rejects %s",
+		c.Kind, c.Frontends[0], c.Routes[0], c.Frontends[1], c.Routes[1], c.Policy, strings.Join(c.Rejected, " and "))
+}
+
+// Resources returns the frontend Resources of the conflict
+func (c *Conflict) Resources() ResourceList { return c.resources }
+
+// findConflict returns the conflict of two frontends of different namespaces, or nil
+func findConflict(a, b *Resource) *Conflict {
+	if a.Namespace() == b.Namespace() {
+		return nil
+	}
+
+	kind := ""
+	switch {
+	case a.Route.normalized().String() == b.Route.normalized().String():
+		kind = ExactConflict
+	case a.Route.covers(b.Route) && a.Priority() >= b.Priority():
+		kind = ShadowConflict
+	case b.Route.covers(a.Route) && b.Priority() >= a.Priority():
+		kind = ShadowConflict
+		a, b = b, a
+	default:
+		return nil
+	}
+
+	c := &Conflict{
+		Kind:      kind,
+		Policy:    ConflictPolicy,
+		Frontends: []string{a.FrontendID(), b.FrontendID()},
+		Routes:    []string{a.Route.String(), b.Route.String()},
+		Since:     time.Now(),
+		resources: ResourceList{a, b},
+	}
+	if kind == ExactConflict && b.FrontendID() < a.FrontendID() {
+		c.Frontends[0], c.Frontends[1] = c.Frontends[1], c.Frontends[0]
+		c.Routes[0], c.Routes[1] = c.Routes[1], c.Routes[0]
+		c.resources[0], c.resources[1] = c.resources[1], c.resources[0]
+	}
+
+	switch ConflictPolicy {
+	case RejectBoth:
+		c.Rejected = []string{c.Frontends[0], c.Frontends[1]}
+	case NamespacePriority:
+		ra, rb := namespaceRank(a.Namespace()), namespaceRank(b.Namespace())
+		if ra != rb {
+			c.Rejected = []string{loser(a, b, ra < rb).FrontendID()}
+			break
+		}
+		fallthrough
+	default:
+		c.Rejected = []string{loser(a, b, older(a, b)).FrontendID()}
+	}
+	return c
+}
+
+// covers is true when every request matched by o is also matched by r
+func (r *Route) covers(o *Route) bool {
+	if hosts := r.Hosts(); len(hosts) > 0 {
+		others := o.Hosts()
+		if len(others) < 1 {
+			return false
+		}
+		for _, h := range others {
+			if !hostCovered(hosts, h) {
+				return false
+			}
+		}
+	}
+
+	for _, part := range r.parts {
+		switch part.kind {
+		case HostPart:
+		case PathPart, PrefixPart:
+			if !pathCovered(part, o) {
+				return false
+			}
+		default:
+			if !o.hasPart(part) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// normalized returns a copy of r with normalized hosts, see normalHost
+func (r *Route) normalized() *Route {
+	rt := *r
+	rt.parts = make([]*routePart, 0, len(r.parts))
+	for _, part := range r.parts {
+		if part.kind == HostPart && !part.regex {
+			p := *part
+			p.value = normalHost(p.value)
+			part = &p
+		}
+		rt.parts = append(rt.parts, part)
+	}
+	return &rt
+}
+
+func (r *Route) hasPart(part *routePart) bool {
+	for _, p := range r.parts {
+		if p.String() == part.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func hostCovered(hosts []*routePart, h *routePart) bool {
+	for _, host := range hosts {
+		switch {
+		case host.regex || h.regex:
+			if host.String() == h.String() {
+				return true
+			}
+		case normalHost(host.value) == normalHost(h.value):
+			return true
+		case host.IsWildcard():
+			domain := strings.TrimPrefix(normalHost(host.value), "*")
+			label := strings.TrimSuffix(normalHost(h.value), domain)
+			if strings.HasSuffix(normalHost(h.value), domain) && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathCovered is true when the path or prefix part matches every path o matches
+func pathCovered(part *routePart, o *Route) bool {
+	if part.regex {
+		return o.hasPart(part)
+	}
+	for _, p := range o.parts {
+		if p.regex || (p.kind != PathPart && p.kind != PrefixPart) {
+			continue
+		}
+		switch {
+		case part.kind == PathPart:
+			if p.kind == PathPart && p.value == part.value {
+				return true
+			}
+		case p.value == part.value, strings.HasPrefix(p.value, strings.TrimSuffix(part.value, "/")+"/"):
+			return true
+		}
+	}
+	return false
+}
+
+func namespaceRank(namespace string) int {
+	for i, ns := range NamespacePriorities {
+		if ns == namespace {
+			return i
+		}
+	}
+	return len(NamespacePriorities)
+}
+
+// older is true when a was created before b, ties go to the lower frontend ID
+func older(a, b *Resource) bool {
+	if a.created.Equal(b.created) {
+		return a.FrontendID() < b.FrontendID()
+	}
+	return a.created.Before(b.created)
+}
+
+func loser(a, b *Resource, aWins bool) *Resource {
+	if aWins {
+		return b
+	}
+	return a
+}
+
+func conflictKey(frontends []string) string {
+	return strings.Join(frontends, " ")
+}
+
+// RecordConflict creates a Warning Event about the conflict for the Services of both frontends
+func RecordConflict(client SuperClient, c *Conflict) {
+	for _, rsc := range c.resources {
+		recordEvent(client, rsc, "RouteConflict", c.String())
+	}
+}
+
+// RecordUnsupportedRoute creates a Warning Event for the Service of a frontend the loadbalancer
+// could not render
+func RecordUnsupportedRoute(client SuperClient, rsc *Resource, message string) {
+	recordEvent(client, rsc, "RouteUnsupported", message)
+}
+
+func recordEvent(client SuperClient, rsc *Resource, reason, message string) {
+	namespace, name := rsc.Service()
+	if client == nil || namespace == "" {
+		return
+	}
+
+	now := kunversioned.Now()
+	ev := &api.Event{
+		ObjectMeta: api.ObjectMeta{GenerateName: name + ".", Namespace: namespace},
+		InvolvedObject: api.ObjectReference{
+			Kind:      "Service",
+			Namespace: namespace,
+			Name:      name,
+		},
+		Reason:         reason,
+		Message:        message,
+		Source:         api.EventSource{Component: "romulus"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           api.EventTypeWarning,
+	}
+	if _, er := client.Events(namespace).Create(ev); er != nil {
+		logger.Debugf("[%v] Unable to record %s Event: %v", rsc.FrontendID(), reason, er)
+	}
+}
@@ -0,0 +1,107 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func routedResource(namespace, name string, created time.Time, annotations map[string]string) *Resource {
+	r := NewResource(GenResourceID(namespace, name, intstrFromPort("web", 80)), "web", annotations)
+	r.service = cacheLookupKey(namespace, name)
+	r.created = created
+	return r
+}
+
+func TestRouteConflicts(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		now   = time.Now()
+		tests = []struct {
+			policy     string
+			priorities []string
+			a, b       map[string]string
+			kind       string
+			rejected   []string
+		}{
+			{OldestWins, nil, map[string]string{"romulus/host": "api.example.com"}, map[string]string{"romulus/host": "www.example.com"}, "", nil},
+			{OldestWins, nil, map[string]string{"romulus/host": "api.example.com"}, map[string]string{"romulus/host": "api.example.com"}, ExactConflict, []string{"team-b.api.web"}},
+			{RejectBoth, nil, map[string]string{"romulus/host": "api.example.com"}, map[string]string{"romulus/host": "api.example.com"}, ExactConflict, []string{"team-a.api.web", "team-b.api.web"}},
+			{NamespacePriority, []string{"team-b"}, map[string]string{"romulus/host": "api.example.com"}, map[string]string{"romulus/host": "api.example.com"}, ExactConflict, []string{"team-a.api.web"}},
+			{NamespacePriority, []string{"ops"}, map[string]string{"romulus/host": "api.example.com"}, map[string]string{"romulus/host": "api.example.com"}, ExactConflict, []string{"team-b.api.web"}},
+			{OldestWins, nil, map[string]string{"romulus/host": "API.example.com"}, map[string]string{"romulus/host": "api.example.com"}, ExactConflict, []string{"team-b.api.web"}},
+			{OldestWins, nil, map[string]string{"romulus/host": "api.example.com."}, map[string]string{"romulus/host": "api.example.com"}, ExactConflict, []string{"team-b.api.web"}},
+			{
+				OldestWins, nil,
+				map[string]string{"romulus/host": "*.Example.COM", "romulus/priority": "9500"},
+				map[string]string{"romulus/host": "Api.example.com.", "romulus/path": "/v1"},
+				ShadowConflict, []string{"team-b.api.web"},
+			},
+			{OldestWins, nil, map[string]string{"romulus/host": "api.example.com", "romulus/prefix": "/v1"}, map[string]string{"romulus/host": "api.example.com", "romulus/prefix": "/v1/users"}, "", nil},
+			{
+				OldestWins, nil,
+				map[string]string{"romulus/host": "*.example.com", "romulus/priority": "9500"},
+				map[string]string{"romulus/host": "api.example.com", "romulus/path": "/v1"},
+				ShadowConflict, []string{"team-b.api.web"},
+			},
+			{
+				OldestWins, nil,
+				map[string]string{"romulus/host": "api.example.com", "romulus/path": "/v1"},
+				map[string]string{"romulus/host": "api.example.com", "romulus/prefix": "/", "romulus/priority": "9500"},
+				ShadowConflict, []string{"team-b.api.web"},
+			},
+		}
+	)
+
+	defer func(p string, n []string) { ConflictPolicy, NamespacePriorities = p, n }(ConflictPolicy, NamespacePriorities)
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	for _, t := range tests {
+		ConflictPolicy, NamespacePriorities = t.policy, t.priorities
+		var (
+			x = NewRouteIndex()
+			a = routedResource("team-a", "api", now.Add(-time.Hour), t.a)
+			b = routedResource("team-b", "api", now, t.b)
+		)
+
+		allowed, found, _ := x.Check(a.ID(), a.Frontends())
+		is.Len(allowed, 1)
+		is.Empty(found)
+		allowed, found, changed := x.Check(b.ID(), b.Frontends())
+		if t.kind == "" {
+			is.Empty(found, "%v %v", t.a, t.b)
+			is.Len(allowed, 1)
+			continue
+		}
+		if is.Len(found, 1, "%v %v", t.a, t.b) {
+			is.Equal(t.kind, found[0].Kind)
+			is.Equal(t.rejected, found[0].Rejected)
+		}
+		is.Len(x.Conflicts(), 1)
+
+		aRejected := t.rejected[0] == a.FrontendID()
+		is.Equal(aRejected, len(changed) == 1, "a should be rendered again when it loses the route")
+		is.Equal(len(allowed) == 0, t.rejected[len(t.rejected)-1] == b.FrontendID())
+
+		changed = x.Release(b.ID())
+		is.Empty(x.Conflicts())
+		is.Equal(aRejected, len(changed) == 1, "a should be rendered again when it gets the route back")
+	}
+}
+
+func TestRouteConflictKeepsSince(te *testing.T) {
+	var (
+		is = assert.New(te)
+		x  = NewRouteIndex()
+		a  = routedResource("team-a", "api", time.Now(), map[string]string{"host": "api.example.com"})
+		b  = routedResource("team-b", "api", time.Now(), map[string]string{"host": "api.example.com"})
+	)
+
+	x.Check(a.ID(), a.Frontends())
+	_, found, _ := x.Check(b.ID(), b.Frontends())
+	is.Len(found, 1)
+	_, found, _ = x.Check(b.ID(), b.Frontends())
+	is.Empty(found, "known conflicts should not be reported again")
+	is.Len(x.Conflicts(), 1)
+}
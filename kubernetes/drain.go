@@ -0,0 +1,74 @@
+package kubernetes
+
+import (
+	"time"
+
+	"github.com/albertrdixon/gearbox/logger"
+)
+
+// drainServers keeps servers that left a Resource (or whose address became NotReady) around with
+// zero weight until the Resource drain period has passed. Servers that were never seen ready are dropped.
+func (k *Cache) drainServers(r *Resource) {
+	var (
+		period  = r.DrainPeriod()
+		now     = time.Now()
+		known   = k.known[r.id]
+		servers = make([]*Server, 0, len(r.servers))
+		current = make(map[string]*Server, len(r.servers))
+	)
+
+	for _, s := range r.servers {
+		current[s.id] = s
+	}
+	if period > 0 {
+		for id, s := range known {
+			if _, ok := current[id]; !ok {
+				d := *s
+				d.weight, d.draining = 0, true
+				current[id] = &d
+				r.servers = append(r.servers, &d)
+			}
+		}
+	}
+
+	next := make(map[string]*Server, len(r.servers))
+	for _, s := range r.servers {
+		key := drainKey(r.id, s.id)
+		if !s.draining {
+			delete(k.drains, key)
+			servers = append(servers, s)
+			next[s.id] = s
+			continue
+		}
+
+		if _, ok := known[s.id]; !ok || period <= 0 {
+			delete(k.drains, key)
+			continue
+		}
+		since, ok := k.drains[key]
+		if !ok {
+			since = now
+			k.drains[key] = since
+		}
+		left := period - now.Sub(since)
+		if left <= 0 {
+			logger.Debugf("[%v] Drain period over for %v", r.id, s)
+			delete(k.drains, key)
+			continue
+		}
+
+		logger.Debugf("[%v] Draining %v for %v", r.id, s, left)
+		r.refreshAfter(left)
+		servers = append(servers, s)
+		next[s.id] = s
+	}
+
+	r.servers = servers
+	k.known[r.id] = next
+}
+
+// drainKey keys the drain start of a server in a Resource, a server may be in several Resources
+// and each drains it on its own
+func drainKey(id, server string) string {
+	return id + "/" + server
+}
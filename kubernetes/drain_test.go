@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainServers(te *testing.T) {
+	var (
+		is = assert.New(te)
+		c  = NewCache()
+		an = map[string]string{"romulus/drain_period": "1m"}
+	)
+
+	r := NewResource("test.foo.80", "", an)
+	r.AddServer("a", HTTP, "10.0.0.1", 80)
+	r.AddServer("b", HTTP, "10.0.0.2", 80)
+	r.addServer(&Server{id: "c", scheme: HTTP, ip: "10.0.0.3", port: 80, draining: true})
+	c.drainServers(r)
+	is.Len(r.Servers(), 2, "never ready servers should not be drained: %v", r.Servers())
+	is.Equal(time.Duration(0), r.RefreshIn())
+
+	r = NewResource("test.foo.80", "", an)
+	r.AddServer("a", HTTP, "10.0.0.1", 80)
+	r.addServer(&Server{id: "b", scheme: HTTP, ip: "10.0.0.2", port: 80, draining: true})
+	c.drainServers(r)
+	if is.Len(r.Servers(), 2) {
+		is.True(r.Servers()[1].IsDraining())
+		is.Equal(0, r.Servers()[1].Weight())
+	}
+	is.True(r.RefreshIn() > 0)
+
+	r = NewResource("test.foo.80", "", an)
+	r.AddServer("a", HTTP, "10.0.0.1", 80)
+	c.drainServers(r)
+	is.Len(r.Servers(), 2, "removed servers should keep draining: %v", r.Servers())
+
+	c.drains[drainKey("test.foo.80", "b")] = time.Now().Add(-2 * time.Minute)
+	r = NewResource("test.foo.80", "", an)
+	r.AddServer("a", HTTP, "10.0.0.1", 80)
+	c.drainServers(r)
+	is.Len(r.Servers(), 1, "servers should be removed after the drain period: %v", r.Servers())
+	is.Equal(time.Duration(0), r.RefreshIn())
+}
+
+func TestDrainServersPerResource(te *testing.T) {
+	var (
+		is = assert.New(te)
+		c  = NewCache()
+		an = map[string]string{"romulus/drain_period": "1m"}
+	)
+
+	for _, id := range []string{"test.foo.web", "test.foo.admin"} {
+		r := NewResource(id, "", an)
+		r.AddServer("a", HTTP, "10.0.0.1", 80)
+		c.drainServers(r)
+	}
+	for _, id := range []string{"test.foo.web", "test.foo.admin"} {
+		r := NewResource(id, "", an)
+		c.drainServers(r)
+		is.Len(r.Servers(), 1, "%s should drain the server", id)
+	}
+
+	c.drains[drainKey("test.foo.web", "a")] = time.Now().Add(-2 * time.Minute)
+	r := NewResource("test.foo.web", "", an)
+	c.drainServers(r)
+	is.Empty(r.Servers(), "the drain period of test.foo.web is over")
+
+	c.drains[drainKey("test.foo.admin", "a")] = time.Now().Add(-2 * time.Minute)
+	r = NewResource("test.foo.admin", "", an)
+	c.drainServers(r)
+	is.Empty(r.Servers(), "test.foo.admin should not restart its drain when test.foo.web finished")
+}
@@ -0,0 +1,140 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"github.com/albertrdixon/gearbox/url"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+const (
+	// ServiceTypeExternalName is not known to the vendored kubernetes api, which also drops
+	// spec.externalName, so the name is read from the external_name annotation.
+	ServiceTypeExternalName api.ServiceType = "ExternalName"
+
+	DefaultResolveInterval = time.Minute
+)
+
+var (
+	lookupHost = net.LookupHost
+
+	defaultPorts = map[string]int{"http": 80, "ws": 80, "https": 443, "wss": 443}
+)
+
+// IsExternal returns true if the Resource is served by hosts outside of the cluster rather
+// than by Endpoints
+func (r *Resource) IsExternal(svc *api.Service) bool {
+	if svc.Spec.Type == ServiceTypeExternalName {
+		return true
+	}
+	_, ok := r.GetAnnotation(ExternalKey)
+	return ok
+}
+
+// ResolveInterval returns how often external hostnames are resolved to IPs, or 0 if the
+// Resource does not resolve hostnames
+func (r *Resource) ResolveInterval() time.Duration {
+	val, _ := r.GetAnnotation(ResolveKey)
+	if b, _ := strconv.ParseBool(val); !b {
+		return 0
+	}
+	if val, ok := r.GetAnnotation(ResolveIntervalKey); ok {
+		if d, er := time.ParseDuration(val); er == nil && d > 0 {
+			return d
+		}
+		logger.Warnf("[%v] Invalid resolve interval %q", r.id, val)
+	}
+	return DefaultResolveInterval
+}
+
+func addServersFromExternal(r *Resource, svc *api.Service, p api.ServicePort) {
+	var (
+		namespace = svc.GetNamespace()
+		name      = svc.GetName()
+		resolve   = r.ResolveInterval()
+	)
+
+	logger.Debugf("[%v] Adding external Servers from %v", r.id, Service(*svc))
+	for _, u := range externalURLs(r, svc, p) {
+		host, port, er := splitHostPort(u, p)
+		if er != nil {
+			logger.Warnf("[%v] Invalid external url %v: %v", r.id, u, er)
+			continue
+		}
+
+		hosts := []string{host}
+		if resolve > 0 && net.ParseIP(host) == nil {
+			// resolve again later whether or not it worked, a failure may be transient
+			r.refreshAfter(resolve)
+			if hosts, er = lookupHost(host); er != nil {
+				logger.Warnf("[%v] Failed to resolve %q: %v", r.id, host, er)
+				continue
+			}
+		}
+		for _, h := range hosts {
+			id := GenServerID(namespace, name, h, port)
+			r.AddServer(id, u.Scheme, h, port)
+		}
+	}
+}
+
+// externalURLs returns the urls from the external annotation or, for ExternalName Services,
+// the url built from the external_name annotation and the Service port
+func externalURLs(r *Resource, svc *api.Service, p api.ServicePort) []*url.URL {
+	var (
+		list   = make([]*url.URL, 0, 1)
+		scheme = HTTP
+	)
+
+	if sc, ok := r.GetAnnotation("scheme"); ok {
+		scheme = sc
+	}
+	if val, ok := r.GetAnnotation(ExternalKey); ok {
+		for _, raw := range strings.Split(val, ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			if !strings.Contains(raw, "://") {
+				raw = fmt.Sprintf("%s://%s", scheme, raw)
+			}
+			if u, er := url.Parse(raw); er == nil {
+				list = append(list, u)
+			} else {
+				logger.Warnf("[%v] Invalid external url %q: %v", r.id, raw, er)
+			}
+		}
+		return list
+	}
+
+	host, ok := r.GetAnnotation(ExternalNameKey)
+	if !ok {
+		logger.Warnf("[%v] %v is of type %s but has no %s annotation", r.id, Service(*svc), ServiceTypeExternalName, ExternalNameKey)
+		return list
+	}
+	if u, er := url.Parse(fmt.Sprintf("%s://%s:%d", scheme, host, p.Port)); er == nil {
+		list = append(list, u)
+	}
+	return list
+}
+
+func splitHostPort(u *url.URL, p api.ServicePort) (string, int, error) {
+	if !strings.Contains(u.Host, ":") {
+		if port, ok := defaultPorts[u.Scheme]; ok {
+			return u.Host, port, nil
+		}
+		return u.Host, p.Port, nil
+	}
+	host, port, er := net.SplitHostPort(u.Host)
+	if er != nil {
+		return "", 0, er
+	}
+	i, er := strconv.Atoi(port)
+	return host, i, er
+}
@@ -0,0 +1,60 @@
+package kubernetes
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalServers(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+	)
+
+	defer func(f func(string) ([]string, error)) { lookupHost = f }(lookupHost)
+	lookupHost = func(host string) ([]string, error) {
+		if host == "api.example.com" {
+			return []string{"1.2.3.4", "1.2.3.5"}, nil
+		}
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+
+	svc := testService("ext", map[string]string{"romulus/external": "https://api.example.com, 10.1.1.1:8080"})
+	list, er := GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+	if is.Len(list[0].Servers(), 2) {
+		is.Equal("api.example.com", list[0].Servers()[0].ip)
+		is.Equal(443, list[0].Servers()[0].port)
+		is.Equal("https", list[0].Servers()[0].scheme)
+		is.Equal(8080, list[0].Servers()[1].port)
+	}
+	is.Equal(time.Duration(0), list[0].RefreshIn())
+
+	svc = testService("ext", map[string]string{
+		"romulus/external_name":    "api.example.com",
+		"romulus/resolve":          "true",
+		"romulus/resolve_interval": "30s",
+	})
+	svc.Spec.Type = ServiceTypeExternalName
+	list, er = GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+	if is.Len(list[0].Servers(), 2) {
+		is.Equal("1.2.3.4", list[0].Servers()[0].ip)
+		is.Equal(80, list[0].Servers()[0].port)
+	}
+	is.Equal(30*time.Second, list[0].RefreshIn())
+
+	svc = testService("ext", map[string]string{"romulus/external": "nowhere.example.com", "romulus/resolve": "true"})
+	list, er = GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+	is.Empty(list[0].Servers(), "unresolvable hosts should not fall back to the Service")
+	is.NotZero(list[0].RefreshIn(), "a failed lookup should be retried")
+}
@@ -0,0 +1,276 @@
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"github.com/ghodss/yaml"
+	"golang.org/x/net/context"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/meta"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+var (
+	documentSeparator  = regexp.MustCompile(`(?m)^---\s*$`)
+	errUnsupportedKind = errors.New("Object kind not supported")
+	manifestExts       = map[string]bool{".yaml": true, ".yml": true, ".json": true}
+)
+
+type fileSource struct {
+	dir      string
+	interval time.Duration
+	objects  map[string]*fileObject
+	mtimes   map[string]time.Time
+}
+
+type fileObject struct {
+	kind string
+	raw  []byte
+	obj  runtime.Object
+}
+
+// NewFileSource returns a Source that reads Service, Endpoints and Ingress manifests
+// from the YAML files in dir and checks them for changes every interval
+func NewFileSource(dir string, interval time.Duration) Source {
+	return &fileSource{
+		dir:      dir,
+		interval: interval,
+		objects:  make(map[string]*fileObject),
+		mtimes:   make(map[string]time.Time),
+	}
+}
+
+func (f *fileSource) Kind() string        { return "file" }
+func (f *fileSource) Client() SuperClient { return nil }
+
+func (f *fileSource) Status() error {
+	fi, er := os.Stat(f.dir)
+	if er != nil {
+		return er
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%q is not a directory", f.dir)
+	}
+	return nil
+}
+
+func (f *fileSource) Run(u Updater, c *Cache, sel Selector, resync time.Duration, ctx context.Context) error {
+	var (
+		selector = selectorFromMap(sel)
+	)
+
+	if resync <= 0 {
+		resync = cacheTTL
+	}
+	logger.Infof("Watching %q for kubernetes manifests", f.dir)
+	go func() {
+		tick := time.NewTicker(f.interval)
+		sync := time.NewTicker(resync)
+		defer tick.Stop()
+		defer sync.Stop()
+
+		f.sync(u, c, selector, false)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				f.sync(u, c, selector, false)
+			case <-sync.C:
+				f.sync(u, c, selector, true)
+			}
+		}
+	}()
+	return nil
+}
+
+func (f *fileSource) sync(u Updater, c *Cache, selector labels.Selector, resync bool) {
+	changed, mtimes, er := f.changed()
+	if er != nil {
+		logger.Warnf("Failed to read %q: %v", f.dir, er)
+		return
+	}
+	if !changed && !resync {
+		return
+	}
+
+	next, er := readManifests(f.dir, selector)
+	if er != nil {
+		// keep the old mtimes so the files are read again on the next tick
+		logger.Warnf("Failed to read manifests from %q: %v", f.dir, er)
+		return
+	}
+	f.mtimes = mtimes
+
+	// Services must be known before the Endpoints and Ingresses referencing them
+	for _, kind := range []string{ServiceKind, EndpointsKind, IngressKind} {
+		for key, obj := range next {
+			if obj.kind != kind {
+				continue
+			}
+			prev, ok := f.objects[key]
+			switch {
+			case !ok:
+				storeFor(c, kind).Add(obj.obj)
+				addDelete(Add, u)(obj.obj)
+			case resync || !bytes.Equal(prev.raw, obj.raw):
+				storeFor(c, kind).Update(obj.obj)
+				update(Update, u)(prev.obj, obj.obj)
+			}
+		}
+	}
+	for key, obj := range f.objects {
+		if _, ok := next[key]; !ok {
+			storeFor(c, obj.kind).Delete(obj.obj)
+			addDelete(Delete, u)(obj.obj)
+		}
+	}
+	f.objects = next
+}
+
+// changed returns true if manifest files were added, removed or modified since the last
+// successful read, and their current mtimes
+func (f *fileSource) changed() (bool, map[string]time.Time, error) {
+	var (
+		changed = false
+		seen    = make(map[string]time.Time)
+	)
+
+	files, er := manifestFiles(f.dir)
+	if er != nil {
+		return false, nil, er
+	}
+	for _, file := range files {
+		fi, er := os.Stat(file)
+		if er != nil {
+			return false, nil, er
+		}
+		seen[file] = fi.ModTime()
+		if mt, ok := f.mtimes[file]; !ok || !mt.Equal(fi.ModTime()) {
+			changed = true
+		}
+	}
+	if len(seen) != len(f.mtimes) {
+		changed = true
+	}
+	return changed, seen, nil
+}
+
+func manifestFiles(dir string) ([]string, error) {
+	list := make([]string, 0, 1)
+	entries, er := ioutil.ReadDir(dir)
+	if er != nil {
+		return list, er
+	}
+	for _, fi := range entries {
+		if fi.IsDir() || !manifestExts[filepath.Ext(fi.Name())] {
+			continue
+		}
+		list = append(list, filepath.Join(dir, fi.Name()))
+	}
+	return list, nil
+}
+
+func readManifests(dir string, selector labels.Selector) (map[string]*fileObject, error) {
+	objects := make(map[string]*fileObject)
+	files, er := manifestFiles(dir)
+	if er != nil {
+		return objects, er
+	}
+
+	for _, file := range files {
+		p, er := ioutil.ReadFile(file)
+		if er != nil {
+			return objects, er
+		}
+		for _, doc := range documentSeparator.Split(string(p), -1) {
+			if len(bytes.TrimSpace([]byte(doc))) == 0 {
+				continue
+			}
+			obj, er := decodeManifest([]byte(doc))
+			if er == errUnsupportedKind {
+				logger.Debugf("Skipping document in %q: %v", file, er)
+				continue
+			}
+			if er != nil {
+				return objects, fmt.Errorf("%q: %v", file, er)
+			}
+			m, _ := meta.Accessor(obj.obj)
+			if !selector.Matches(labels.Set(m.GetLabels())) {
+				continue
+			}
+			key := fmt.Sprintf("%s/%s", obj.kind, cacheLookupKey(m.GetNamespace(), m.GetName()))
+			objects[key] = obj
+		}
+	}
+	return objects, nil
+}
+
+func decodeManifest(doc []byte) (*fileObject, error) {
+	var (
+		kind struct {
+			Kind string `json:"kind"`
+		}
+		obj runtime.Object
+	)
+
+	if er := yaml.Unmarshal(doc, &kind); er != nil {
+		return nil, er
+	}
+	switch kind.Kind {
+	default:
+		return nil, errUnsupportedKind
+	case "Service":
+		obj = &api.Service{}
+	case "Endpoints":
+		obj = &api.Endpoints{}
+	case "Ingress":
+		obj = &extensions.Ingress{}
+	}
+	if er := yaml.Unmarshal(doc, obj); er != nil {
+		return nil, er
+	}
+
+	m, er := meta.Accessor(obj)
+	if er != nil {
+		return nil, er
+	}
+	if m.GetNamespace() == "" {
+		m.SetNamespace(api.NamespaceDefault)
+	}
+	return &fileObject{kind: kindOf(obj), raw: doc, obj: obj}, nil
+}
+
+func kindOf(obj runtime.Object) string {
+	switch obj.(type) {
+	case *api.Service:
+		return ServiceKind
+	case *api.Endpoints:
+		return EndpointsKind
+	case *extensions.Ingress:
+		return IngressKind
+	}
+	return ""
+}
+
+func storeFor(c *Cache, kind string) cache.Store {
+	switch kind {
+	case ServiceKind:
+		return c.service
+	case EndpointsKind:
+		return c.endpoints
+	}
+	return c.ingress
+}
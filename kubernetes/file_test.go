@@ -0,0 +1,108 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) Add(obj interface{})          { r.events = append(r.events, Add) }
+func (r *recorder) Delete(obj interface{})       { r.events = append(r.events, Delete) }
+func (r *recorder) Update(old, next interface{}) { r.events = append(r.events, Update) }
+
+func writeManifests(t *testing.T, dir string, files ...string) {
+	var docs = make([]byte, 0, 1)
+	for _, f := range files {
+		p, er := ioutil.ReadFile(path.Join("test", f))
+		require.NoError(t, er)
+		docs = append(docs, []byte("\n---\n")...)
+		docs = append(docs, p...)
+	}
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "manifests.yaml"), docs, 0644))
+}
+
+func TestReadManifests(te *testing.T) {
+	var (
+		is = assert.New(te)
+	)
+
+	dir, er := ioutil.TempDir("", "romulus-file-source")
+	require.NoError(te, er)
+	defer os.RemoveAll(dir)
+	writeManifests(te, dir, "default-svc.yaml", "default-endpoints.yaml", "default-ingress.yaml")
+
+	objs, er := readManifests(dir, labels.Everything())
+	if is.NoError(er) && is.Len(objs, 3) {
+		is.Contains(objs, "service/test/bar")
+		is.Contains(objs, "endpoints/test/bar")
+		is.Contains(objs, "ingress/test/foo")
+	}
+
+	objs, er = readManifests(dir, selectorFromMap(Selector{"route": "public"}))
+	if is.NoError(er) {
+		is.Empty(objs)
+	}
+}
+
+func TestFileSourceSync(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		rec = new(recorder)
+		c   = NewCache()
+	)
+
+	dir, er := ioutil.TempDir("", "romulus-file-source")
+	require.NoError(te, er)
+	defer os.RemoveAll(dir)
+
+	f := NewFileSource(dir, 0).(*fileSource)
+	writeManifests(te, dir, "default-svc.yaml", "default-endpoints.yaml")
+	f.sync(rec, c, labels.Everything(), false)
+	is.Equal([]string{Add, Add}, rec.events)
+
+	rec.events = nil
+	f.sync(rec, c, labels.Everything(), true)
+	is.Equal([]string{Update, Update}, rec.events)
+
+	rec.events = nil
+	os.Remove(path.Join(dir, "manifests.yaml"))
+	f.sync(rec, c, labels.Everything(), false)
+	is.Equal([]string{Delete, Delete}, rec.events)
+}
+
+func TestFileSourceRetriesBadManifests(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		rec = new(recorder)
+		c   = NewCache()
+	)
+
+	dir, er := ioutil.TempDir("", "romulus-file-source")
+	require.NoError(te, er)
+	defer os.RemoveAll(dir)
+
+	f := NewFileSource(dir, 0).(*fileSource)
+	writeManifests(te, dir, "default-svc.yaml", "default-endpoints.yaml")
+	f.sync(rec, c, labels.Everything(), false)
+	is.Equal([]string{Add, Add}, rec.events)
+
+	rec.events = nil
+	file := path.Join(dir, "manifests.yaml")
+	p, er := ioutil.ReadFile(file)
+	require.NoError(te, er)
+	require.NoError(te, ioutil.WriteFile(file, append(p, []byte("\n---\nkind: Service\nmetadata: [half\n")...), 0644))
+	f.sync(rec, c, labels.Everything(), false)
+	is.Empty(rec.events, "an undecodable manifest must not delete its objects")
+	changed, _, er := f.changed()
+	is.NoError(er)
+	is.True(changed, "the file should be read again on the next sync")
+}
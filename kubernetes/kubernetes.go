@@ -9,6 +9,8 @@ import (
 
 	"github.com/albertrdixon/gearbox/logger"
 	"k8s.io/kubernetes/pkg/api"
+	kunversioned "k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apimachinery/registered"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/client/cache"
 	"k8s.io/kubernetes/pkg/client/unversioned"
@@ -23,10 +25,25 @@ import (
 var (
 	Keyspace string
 
+	// ServiceFallback controls whether a Resource without Endpoints falls back to the Service IPs
+	ServiceFallback = true
+
+	// BackendMode is the default backend mode, see BackendModeKey
+	BackendMode = EndpointsMode
+
+	// ConfigMap is the "namespace/name" of the ConfigMap with global annotation defaults and overrides
+	ConfigMap string
+
+	// PodRoutes enables routing to annotated Pods without a Service
+	PodRoutes = false
+
 	resources = map[string]runtime.Object{
-		ServicesKind:  &api.Service{},
-		EndpointsKind: &api.Endpoints{},
-		IngressesKind: &extensions.Ingress{},
+		ServicesKind:   &api.Service{},
+		EndpointsKind:  &api.Endpoints{},
+		IngressesKind:  &extensions.Ingress{},
+		PodsKind:       &api.Pod{},
+		NodesKind:      &api.Node{},
+		NamespacesKind: &api.Namespace{},
 	}
 )
 
@@ -38,41 +55,130 @@ const (
 	Update = "UPDATE"
 	Delete = "DELETE"
 
-	ServiceKind   = "service"
-	ServicesKind  = "services"
-	IngressKind   = "ingress"
-	IngressesKind = "ingresses"
-	EndpointsKind = "endpoints"
+	ServiceKind    = "service"
+	ServicesKind   = "services"
+	IngressKind    = "ingress"
+	IngressesKind  = "ingresses"
+	EndpointsKind  = "endpoints"
+	PodsKind       = "pods"
+	NodesKind      = "nodes"
+	NamespacesKind = "namespaces"
+	ConfigMapsKind = "configmaps"
 
 	HostPart   = "host"
 	PathPart   = "path"
 	PrefixPart = "prefix"
 	MethodPart = "method"
 	HeaderPart = "header"
+	QueryPart  = "query"
+	CookiePart = "cookie"
 
 	HostKey    = "host"
+	HostsKey   = "hosts"
 	PathKey    = "path"
 	PrefixKey  = "prefix"
 	MethodsKey = "methods"
 	HeadersKey = "headers"
+	QueryKey   = "query"
+	CookiesKey = "cookies"
+
+	DrainPeriodKey      = "drain_period"
+	ServiceFallbackKey  = "service_fallback"
+	WeightKey           = "weight"
+	WeightsKey          = "weights"
+	WeightLabelKey      = "weight_label"
+	PodWeightsKey       = "pod_weights"
+	SplitKey            = "split"
+	ExternalKey         = "external"
+	ExternalNameKey     = "external_name"
+	ResolveKey          = "resolve"
+	ResolveIntervalKey  = "resolve_interval"
+	BackendModeKey      = "backend_mode"
+	BackendKey          = "backend"
+	AllowIngressFromKey = "allow_ingress_from"
+	StickyKey           = "sticky"
+	StickyCookieKey     = "sticky_cookie"
+	PodGroupKey         = "group"
+	PodPortKey          = "port"
+	StripPrefixKey      = "strip_prefix"
+	RewritePathKey      = "rewrite_path"
+	PriorityKey         = "priority"
+	RouteKey            = "route"
+	RedirectHostKey     = "redirect_host"
+	RedirectToKey       = "redirect_to"
+	RedirectCodeKey     = "redirect_code"
+	NoEndpointsKey      = "no_endpoints_response"
+
+	EndpointsMode = "endpoints"
+	NodePortMode  = "nodeport"
 
 	HTTP  = "http"
 	HTTPS = "https"
 	TCP   = "tcp"
+
+	ExtensionsGroupVersion = "extensions/v1beta1"
 )
 
-func NewClient(kubeapi, user, pass string, insecure bool) (*Client, error) {
+func NewClient(kubeapi, version, user, pass string, insecure bool) (*Client, error) {
 	config, er := getKubeConfig(kubeapi, user, pass, insecure)
 	if er != nil {
 		return nil, er
 	}
+	if er := setGroupVersion(config, version); er != nil {
+		return nil, er
+	}
 
 	cl, er := unversioned.New(config)
 	if er != nil {
 		return nil, er
 	}
 
-	return &Client{Client: cl}, nil
+	// unversioned.New always applies the default API version, so rebuild the
+	// RESTClient against the version we were asked for.
+	rc, er := restClientFor(config)
+	if er != nil {
+		return nil, er
+	}
+	cl.RESTClient = rc
+
+	return &Client{Client: cl, version: *config.GroupVersion}, nil
+}
+
+func setGroupVersion(config *unversioned.Config, version string) error {
+	if version == "" {
+		gv := registered.GroupOrDie(api.GroupName).GroupVersion
+		config.GroupVersion = &gv
+		return nil
+	}
+
+	gv, er := kunversioned.ParseGroupVersion(version)
+	if er != nil {
+		return fmt.Errorf("Invalid kubernetes api version %q: %v", version, er)
+	}
+	if gv.Group != api.GroupName || !registered.IsEnabledVersion(gv) {
+		return fmt.Errorf("Unsupported kubernetes api version %q, supported versions: %v",
+			version, registered.EnabledVersionsForGroup(api.GroupName))
+	}
+	config.GroupVersion = &gv
+	return nil
+}
+
+func restClientFor(config *unversioned.Config) (*unversioned.RESTClient, error) {
+	var (
+		c  = *config
+		gv = *config.GroupVersion
+	)
+
+	if er := unversioned.SetKubernetesDefaults(&c); er != nil {
+		return nil, er
+	}
+	vi, er := registered.GroupOrDie(api.GroupName).InterfacesFor(gv)
+	if er != nil {
+		return nil, er
+	}
+	c.GroupVersion = &gv
+	c.Codec = vi.Codec
+	return unversioned.RESTClientFor(&c)
 }
 
 func getKubeConfig(url, user, pass string, insecure bool) (*unversioned.Config, error) {
@@ -107,11 +213,55 @@ func (c *Client) GetUnversionedClient() *unversioned.Client {
 	return c.Client
 }
 
+func (c *Client) GetVersion() string {
+	return c.version.String()
+}
+
 func Status(client *Client) error {
 	_, er := client.ServerVersion()
 	return er
 }
 
+// NegotiateVersion verifies the api server serves the version the Client was
+// configured with.
+func NegotiateVersion(client *Client) error {
+	var (
+		gv   = client.version
+		list = []kunversioned.GroupVersion{gv}
+	)
+
+	_, er := unversioned.NegotiateVersion(client.Client, &unversioned.Config{GroupVersion: &gv}, &gv, list)
+	return er
+}
+
+// IngressSupported uses the discovery api to determine if the api server serves
+// Ingress objects from the extensions api group.
+func IngressSupported(client *Client) (bool, error) {
+	return servesResource(client.GetDiscoveryClient(), ExtensionsGroupVersion, IngressesKind)
+}
+
+func servesResource(dc *unversioned.DiscoveryClient, groupVersion, kind string) (bool, error) {
+	groups, er := dc.ServerGroups()
+	if er != nil {
+		return false, er
+	}
+	for _, gv := range unversioned.ExtractGroupVersions(groups) {
+		if gv != groupVersion {
+			continue
+		}
+		list, er := dc.ServerResourcesForGroupVersion(groupVersion)
+		if er != nil {
+			return false, er
+		}
+		for _, rsc := range list.APIResources {
+			if rsc.Name == kind {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 func CreateStore(kind string, c cache.Getter, sel Selector, resync time.Duration, ctx context.Context) (cache.Store, error) {
 	obj, ok := resources[kind]
 	if !ok {
@@ -154,6 +304,25 @@ func CreateFullController(kind string, w Updater, c cache.Getter, sel Selector,
 	return framework.NewInformer(getListWatch(kind, c, sl), obj, resync, handler)
 }
 
+// CreatePodController watches all Pods, which are looked up for server weights. The Updater gets
+// the events of routed Pods (see PodRoutes), Pod deletions and updates changing server weights.
+func CreatePodController(w Updater, c cache.Getter, resync time.Duration) (cache.Store, *framework.Controller) {
+	handler := framework.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if PodRoutes {
+				addDelete(Add, w)(obj)
+			}
+		},
+		DeleteFunc: addDelete(Delete, w),
+		UpdateFunc: func(old, next interface{}) {
+			if PodRoutes || PodWeightChanged(old, next) {
+				update(Update, w)(old, next)
+			}
+		},
+	}
+	return framework.NewInformer(getListWatch(PodsKind, c, labels.Everything()), &api.Pod{}, resync, handler)
+}
+
 func getListWatch(kind string, getter cache.Getter, selector labels.Selector) *cache.ListWatch {
 	return &cache.ListWatch{
 		ListFunc: func(options api.ListOptions) (runtime.Object, error) {
@@ -224,6 +393,14 @@ func logCallback(callback string, obj interface{}) error {
 		logger.Infof(format, callback, Service(*t))
 	case *api.Endpoints:
 		logger.Infof(format, callback, Endpoints(*t))
+	case *api.Pod:
+		logger.Debugf("%s Pod(%q)", callback, cacheLookupKey(t.GetNamespace(), t.GetName()))
+	case *api.Node:
+		logger.Infof(format, callback, Node(*t))
+	case *api.Namespace:
+		logger.Infof("%s Namespace(%q)", callback, t.GetName())
+	case *extensions.ConfigMap:
+		logger.Infof("%s ConfigMap(%q)", callback, cacheLookupKey(t.GetNamespace(), t.GetName()))
 	}
 	return nil
 }
@@ -9,6 +9,8 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
 	"k8s.io/kubernetes/pkg/runtime"
 )
@@ -44,3 +46,23 @@ func objectFromFile(t *testing.T, name, kind string, obj runtime.Object) {
 		// t.Logf("Endpoints: %v", spew.Sdump(obj))
 	}
 }
+
+func TestLogCallback(te *testing.T) {
+	var (
+		is      = assert.New(te)
+		objects = []interface{}{
+			&api.Service{},
+			&api.Endpoints{},
+			&extensions.Ingress{},
+			&api.Pod{},
+			&api.Node{},
+			&api.Namespace{},
+			&extensions.ConfigMap{},
+		}
+	)
+
+	for _, obj := range objects {
+		is.NoError(logCallback(Add, obj), "%T events must reach the Updater", obj)
+	}
+	is.Error(logCallback(Add, "not an object"))
+}
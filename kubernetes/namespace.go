@@ -0,0 +1,173 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const anyNamespace = "*"
+
+func (k *Cache) SetNamespaceStore(store cache.Store) {
+	k.namespace = store
+}
+
+func (k *Cache) GetNamespace(client unversioned.Interface, name string) (*api.Namespace, error) {
+	var (
+		key = cacheLookupKey("", name)
+	)
+
+	logger.Debugf("Looking up Namespace(%q) in cache", key)
+	obj, ok, er := k.namespace.Get(key)
+	if er != nil {
+		return nil, er
+	}
+	if !ok {
+		if client == nil {
+			return nil, fmt.Errorf("Could not find Namespace %q", key)
+		}
+		logger.Debugf("Looking up Namespace(%q) on server", key)
+		if ns, er := client.Namespaces().Get(name); er == nil {
+			k.namespace.Add(ns)
+			return ns, nil
+		}
+		return nil, fmt.Errorf("Could not find Namespace %q", key)
+	}
+
+	ns, ok := obj.(*api.Namespace)
+	if !ok {
+		return nil, errors.New("Namespace cache returned non-Namespace object")
+	}
+	return ns, nil
+}
+
+// withNamespaceDefaults returns the annotations of an object in namespace merged over the romulus
+// annotations of the Namespace, which serve as defaults for all Services of the namespace.
+func (k *Cache) withNamespaceDefaults(client unversioned.Interface, namespace string, anno map[string]string) map[string]string {
+	ns, er := k.GetNamespace(client, namespace)
+	if er != nil {
+		return anno
+	}
+	defaults := namespaceDefaults(ns)
+	if len(defaults) < 1 {
+		return anno
+	}
+
+	merged := make(map[string]string, len(defaults)+len(anno))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range anno {
+		merged[key] = value
+	}
+	return merged
+}
+
+// namespaceDefaults returns the romulus annotations of a Namespace that apply to its Services
+func namespaceDefaults(ns *api.Namespace) map[string]string {
+	var (
+		m      = make(map[string]string)
+		policy = path.Join(Keyspace, AllowIngressFromKey)
+	)
+
+	for key, value := range ns.ObjectMeta.Annotations {
+		if strings.HasPrefix(key, Keyspace) && key != policy {
+			m[key] = value
+		}
+	}
+	return m
+}
+
+// SameNamespace returns true if old and next are the same Namespace and neither its default
+// annotations nor its Ingress permissions changed
+func SameNamespace(old, next interface{}) bool {
+	a, ok := old.(*api.Namespace)
+	if !ok {
+		return false
+	}
+	b, ok := next.(*api.Namespace)
+	if !ok {
+		return false
+	}
+	key := path.Join(Keyspace, AllowIngressFromKey)
+	return a.GetName() == b.GetName() &&
+		a.ObjectMeta.Labels[key] == b.ObjectMeta.Labels[key] &&
+		a.ObjectMeta.Annotations[key] == b.ObjectMeta.Annotations[key] &&
+		reflect.DeepEqual(namespaceDefaults(a), namespaceDefaults(b))
+}
+
+// namespaceResources returns the Resources of all Services in a Namespace
+func namespaceResources(store *Cache, client SuperClient, ns *api.Namespace) ResourceList {
+	var list ResourceList = make([]*Resource, 0, 1)
+	for _, obj := range store.service.List() {
+		if svc, ok := obj.(*api.Service); ok && svc.GetNamespace() == ns.GetName() {
+			list = append(list, resourcesFromService(store, client, svc)...)
+		}
+	}
+	return list
+}
+
+// ingressBackendService returns the namespace and name of the Service behind an Ingress backend.
+// Backends live in the namespace of the Ingress unless the Ingress points them at another
+// namespace with romulus/backend.<service>: 'namespace/service'.
+func ingressBackendService(in *extensions.Ingress, backend extensions.IngressBackend) (string, string) {
+	var (
+		namespace = in.GetNamespace()
+		name      = backend.ServiceName
+		key       = path.Join(Keyspace, strings.Join([]string{BackendKey, name}, "."))
+	)
+
+	if val, ok := in.ObjectMeta.Annotations[key]; ok {
+		bits := strings.SplitN(strings.TrimSpace(val), "/", 2)
+		if len(bits) == 2 && bits[0] != "" && bits[1] != "" {
+			return bits[0], bits[1]
+		}
+		logger.Warnf("Invalid backend %q in %v, must be 'namespace/service'", val, Ingress(*in))
+	}
+	return namespace, name
+}
+
+// ingressPermitted returns true if the Ingress may route to Services in namespace. Other
+// namespaces have to allow the Ingress namespace with a romulus/allow_ingress_from label
+// (a single namespace) or annotation (a comma separated list of namespaces, or *).
+func (k *Cache) ingressPermitted(client unversioned.Interface, in *extensions.Ingress, namespace string) bool {
+	if namespace == in.GetNamespace() {
+		return true
+	}
+
+	ns, er := k.GetNamespace(client, namespace)
+	if er != nil {
+		logger.Warnf("%v may not route to namespace %q: %v", Ingress(*in), namespace, er)
+		return false
+	}
+	key := path.Join(Keyspace, AllowIngressFromKey)
+	if ns.ObjectMeta.Labels[key] == in.GetNamespace() {
+		return true
+	}
+	for _, allowed := range strings.Split(ns.ObjectMeta.Annotations[key], ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == anyNamespace || allowed == in.GetNamespace() {
+			return true
+		}
+	}
+	logger.Warnf("%v may not route to namespace %q, it does not allow Ingresses from %q", Ingress(*in), namespace, in.GetNamespace())
+	return false
+}
+
+// ingressFor returns the Ingress routing to the Service, if it is still permitted to
+func (k *Cache) ingressFor(client SuperClient, svc *api.Service) (*extensions.Ingress, bool) {
+	in, er := k.GetIngress(client, svc.GetNamespace(), svc.GetName())
+	if er != nil {
+		return nil, false
+	}
+	return in, k.ingressPermitted(client, in, svc.GetNamespace())
+}
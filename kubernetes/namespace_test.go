@@ -0,0 +1,143 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+func testIngress(namespace, name string, annotations map[string]string, backends ...string) *extensions.Ingress {
+	paths := make([]extensions.HTTPIngressPath, 0, len(backends))
+	for _, b := range backends {
+		paths = append(paths, extensions.HTTPIngressPath{
+			Path:    "/" + b,
+			Backend: extensions.IngressBackend{ServiceName: b, ServicePort: intstr.FromString("web")},
+		})
+	}
+	return &extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations},
+		Spec: extensions.IngressSpec{Rules: []extensions.IngressRule{{
+			Host: "www.example.com",
+			IngressRuleValue: extensions.IngressRuleValue{
+				HTTP: &extensions.HTTPIngressRuleValue{Paths: paths},
+			},
+		}}},
+	}
+}
+
+func TestCrossNamespaceIngress(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		in   = testIngress("edge", "public", map[string]string{
+			"romulus/backend.api":   "team-a/api",
+			"romulus/backend.admin": "team-b/admin",
+		}, "api", "admin")
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	c.namespace.Add(&api.Namespace{ObjectMeta: api.ObjectMeta{
+		Name:        "team-a",
+		Annotations: map[string]string{"romulus/allow_ingress_from": "ops, edge"},
+	}})
+	c.namespace.Add(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: "team-b"}})
+	for _, ns := range []string{"team-a", "team-b"} {
+		for _, name := range []string{"api", "admin"} {
+			svc := testService(name, nil)
+			svc.Namespace = ns
+			en := testEndpoints(name, "10.0.0.1")
+			en.Namespace = ns
+			c.service.Add(svc)
+			c.endpoints.Add(en)
+		}
+	}
+	c.ingress.Add(in)
+
+	list, er := GenResources(c, nil, in)
+	must.NoError(er)
+	if is.Len(list, 1, "team-b does not allow Ingresses from edge") {
+		is.Equal("team-a.api.web", list[0].ID())
+		is.Equal("Route(host(`www.example.com`) && path(`/api`))", list[0].Route.String())
+	}
+
+	svc, _ := c.GetService(nil, "team-a", "api")
+	list, er = GenResources(c, nil, svc)
+	must.NoError(er)
+	if is.Len(list, 1) {
+		is.Equal("Route(host(`www.example.com`) && path(`/api`))", list[0].Route.String(), "Service changes should keep the Ingress route")
+	}
+
+	c.namespace.Update(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: "team-a"}})
+	list, _ = GenResources(c, nil, svc)
+	if is.Len(list, 1) {
+		is.Equal("Route()", list[0].Route.String(), "revoked permission should drop the Ingress route")
+	}
+}
+
+func TestNamespaceDefaults(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		ns   = &api.Namespace{ObjectMeta: api.ObjectMeta{
+			Name: "test",
+			Annotations: map[string]string{
+				"romulus/pass_host_header":   "true",
+				"romulus/read_timeout":       "5s",
+				"romulus/allow_ingress_from": "*",
+				"other/annotation":           "x",
+			},
+		}}
+		svc = testService("api", map[string]string{"romulus/read_timeout": "10s"})
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	c.namespace.Add(ns)
+	c.service.Add(svc)
+	c.service.Add(testService("other", nil))
+
+	list, er := GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+	val, _ := list[0].GetAnnotation("read_timeout")
+	is.Equal("10s", val, "Service annotations override Namespace defaults")
+	val, _ = list[0].GetAnnotation("pass_host_header")
+	is.Equal("true", val)
+	_, ok := list[0].GetAnnotation("allow_ingress_from")
+	is.False(ok)
+
+	list, er = GenResources(c, nil, ns)
+	is.NoError(er)
+	is.Empty(list)
+	deps, er := GenDependentResources(c, nil, ns)
+	must.NoError(er)
+	is.Len(deps, 2)
+
+	next := *ns
+	next.Labels = map[string]string{"team": "a"}
+	is.True(SameNamespace(ns, &next))
+	next.Annotations = map[string]string{"romulus/read_timeout": "1s", "romulus/write_timeout": "3s"}
+	is.False(SameNamespace(ns, &next))
+
+	c.ObjectAdded(&next)
+	deps, er = GenDependentResources(c, nil, &next)
+	must.NoError(er)
+	if is.Len(deps, 2) {
+		val, _ := deps[1].GetAnnotation("write_timeout")
+		is.Equal("3s", val, "the updated Namespace defaults should apply right away")
+	}
+	c.ObjectDeleted(&next)
+	deps, er = GenDependentResources(c, nil, &next)
+	must.NoError(er)
+	if is.Len(deps, 2) {
+		_, ok := deps[1].GetAnnotation("write_timeout")
+		is.False(ok, "the deleted Namespace defaults should not apply")
+	}
+}
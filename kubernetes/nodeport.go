@@ -0,0 +1,133 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	"github.com/albertrdixon/gearbox/logger"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// nodeAddressTypes is the order in which Node addresses are picked for NodePort servers
+var nodeAddressTypes = []api.NodeAddressType{api.NodeInternalIP, api.NodeLegacyHostIP, api.NodeExternalIP}
+
+// BackendMode returns how servers are built for the Resource, from Endpoints (the default)
+// or from Node addresses and the Service NodePort
+func (r *Resource) BackendMode() string {
+	if val, ok := r.GetAnnotation(BackendModeKey); ok {
+		if val == EndpointsMode || val == NodePortMode {
+			return val
+		}
+		logger.Warnf("[%v] Invalid backend mode %q, using %q", r.id, val, BackendMode)
+	}
+	return BackendMode
+}
+
+// UsesNodePorts returns true if the Resource servers are Node addresses
+func (r *Resource) UsesNodePorts() bool {
+	return r.BackendMode() == NodePortMode
+}
+
+// Node is a printable api.Node
+type Node api.Node
+
+func (n Node) String() string {
+	return fmt.Sprintf(`Node(Name=%q, Ready=%v, Unschedulable=%v)`, n.ObjectMeta.Name, nodeReady((*api.Node)(&n)), n.Spec.Unschedulable)
+}
+
+// SameNode returns true if old and next are the same Node and nothing that NodePort servers are
+// built from changed, e.g. for status heartbeats
+func SameNode(old, next interface{}) bool {
+	a, ok := old.(*api.Node)
+	if !ok {
+		return false
+	}
+	b, ok := next.(*api.Node)
+	if !ok {
+		return false
+	}
+	return a.GetName() == b.GetName() &&
+		nodeAddress(a) == nodeAddress(b) &&
+		nodeReady(a) == nodeReady(b) &&
+		a.Spec.Unschedulable == b.Spec.Unschedulable
+}
+
+// nodePortResources returns the Resources of all Services using NodePort servers
+func nodePortResources(store *Cache, client SuperClient) ResourceList {
+	var list ResourceList = make([]*Resource, 0, 1)
+	for _, obj := range store.service.List() {
+		svc, ok := obj.(*api.Service)
+		if !ok {
+			continue
+		}
+		for _, r := range resourcesFromService(store, client, svc) {
+			if r.UsesNodePorts() {
+				list = append(list, r)
+			}
+		}
+	}
+	return list
+}
+
+func addServersFromNodes(store *Cache, r *Resource, svc *api.Service, p api.ServicePort) {
+	var (
+		namespace = svc.GetNamespace()
+		name      = svc.GetName()
+		scheme    = HTTP
+	)
+
+	if sc, ok := r.GetAnnotation("scheme"); ok {
+		scheme = sc
+	}
+	if p.NodePort == 0 {
+		logger.Warnf("[%v] %v has no node port for port %v", r.id, Service(*svc), p.Port)
+		return
+	}
+
+	logger.Debugf("[%v] Adding Servers from Nodes on port %d", r.id, p.NodePort)
+	for _, obj := range store.node.List() {
+		node, ok := obj.(*api.Node)
+		if !ok {
+			continue
+		}
+		ip := nodeAddress(node)
+		if ip == "" {
+			logger.Warnf("[%v] %v has no address", r.id, Node(*node))
+			continue
+		}
+
+		ref := &api.ObjectReference{Kind: "Node", Name: node.GetName(), UID: node.GetUID()}
+		s := &Server{
+			id:     GenServerIDFromRef(namespace, name, ref, ip, p.NodePort),
+			scheme: scheme,
+			ip:     ip,
+			port:   p.NodePort,
+			weight: 1,
+			target: ref,
+		}
+		if !nodeReady(node) || node.Spec.Unschedulable {
+			s.weight, s.draining = 0, true
+		}
+		r.addServer(s)
+	}
+}
+
+func nodeAddress(node *api.Node) string {
+	for _, t := range nodeAddressTypes {
+		for _, addr := range node.Status.Addresses {
+			if addr.Type == t && addr.Address != "" {
+				return addr.Address
+			}
+		}
+	}
+	return ""
+}
+
+func nodeReady(node *api.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == api.NodeReady {
+			return c.Status == api.ConditionTrue
+		}
+	}
+	return false
+}
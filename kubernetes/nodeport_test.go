@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func testNode(name, ip string, ready, unschedulable bool) *api.Node {
+	status := api.ConditionTrue
+	if !ready {
+		status = api.ConditionFalse
+	}
+	return &api.Node{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec:       api.NodeSpec{Unschedulable: unschedulable},
+		Status: api.NodeStatus{
+			Addresses:  []api.NodeAddress{{Type: api.NodeExternalIP, Address: "1.1.1.1"}, {Type: api.NodeInternalIP, Address: ip}},
+			Conditions: []api.NodeCondition{{Type: api.NodeReady, Status: status}},
+		},
+	}
+}
+
+func TestNodePortServers(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		svc  = testService("web", map[string]string{"romulus/backend_mode": "nodeport"})
+	)
+
+	svc.Spec.Type = api.ServiceTypeNodePort
+	svc.Spec.Ports[0].NodePort = 30080
+	c.service.Add(svc)
+	c.service.Add(testService("other", nil))
+	c.endpoints.Add(testEndpoints("web", "10.0.0.1"))
+	c.node.Add(testNode("node-1", "192.168.0.1", true, false))
+	c.node.Add(testNode("node-2", "192.168.0.2", false, false))
+	c.node.Add(testNode("node-3", "192.168.0.3", true, true))
+
+	list, er := GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+	if is.Len(list[0].Servers(), 1) {
+		is.Equal("192.168.0.1", list[0].Servers()[0].ip)
+		is.Equal(30080, list[0].Servers()[0].port)
+	}
+
+	list, er = GenResources(c, nil, testNode("node-4", "192.168.0.4", true, false))
+	is.NoError(er)
+	is.Empty(list)
+
+	c.node.Add(testNode("node-4", "192.168.0.4", true, false))
+	deps, er := GenDependentResources(c, nil, testNode("node-4", "192.168.0.4", true, false))
+	must.NoError(er)
+	if is.Len(deps, 1, "only NodePort Resources depend on Nodes") {
+		is.Len(deps[0].Servers(), 2)
+	}
+
+	old, next := testNode("node-1", "192.168.0.1", true, false), testNode("node-1", "192.168.0.1", true, false)
+	next.Status.Conditions[0].Reason = "heartbeat"
+	is.True(SameNode(old, next))
+	next.Spec.Unschedulable = true
+	is.False(SameNode(old, next))
+	is.False(SameNode(svc, svc))
+}
@@ -0,0 +1,222 @@
+package kubernetes
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+const podGroupPrefix = "pod-"
+
+// PodGroups returns the keys ("namespace/group") of the pod groups objs are routed in. Pods are
+// routed when they carry a romulus port annotation and are grouped by the romulus group
+// annotation, or by their name if they have none.
+func PodGroups(objs ...interface{}) []string {
+	var (
+		list = make([]string, 0, len(objs))
+		seen = make(map[string]bool, len(objs))
+	)
+
+	for _, obj := range objs {
+		pod, ok := obj.(*api.Pod)
+		if !ok {
+			continue
+		}
+		if key, ok := podGroupKey(pod); ok && !seen[key] {
+			seen[key] = true
+			list = append(list, key)
+		}
+	}
+	return list
+}
+
+// GenPodResources returns the Resources of the given pod groups and, separately, the Resources
+// of the groups that have no pods left and have to be removed.
+func GenPodResources(store *Cache, client unversioned.Interface, groups ...string) (ResourceList, ResourceList) {
+	var (
+		list     ResourceList = make([]*Resource, 0, len(groups))
+		removals ResourceList = make([]*Resource, 0, 1)
+	)
+
+	for _, key := range groups {
+		pods := store.podGroup(key)
+		if len(pods) < 1 {
+			logger.Debugf("No pods left in pod group %q", key)
+			for _, id := range store.podGroupIDs(key) {
+				removals = append(removals, &Resource{Route: NewRoute(id, nil), id: id, podGroup: key})
+			}
+			store.forgetPodGroup(key)
+			continue
+		}
+
+		r := resourceFromPods(store, client, key, pods)
+		for _, id := range store.podGroupIDs(key) {
+			if id != r.id {
+				removals = append(removals, &Resource{Route: NewRoute(id, nil), id: id, podGroup: key})
+				delete(store.podGroups[key], id)
+			}
+		}
+		list = append(list, r)
+	}
+	Sort(list, ByID)
+	return list, removals
+}
+
+// PodAdded records a new or updated Pod ahead of the Pod cache, so its group renders with it
+func (k *Cache) PodAdded(pod *api.Pod) {
+	k.pod.Add(pod)
+}
+
+// PodDeleted forgets a Pod ahead of the Pod cache, so its group renders without it
+func (k *Cache) PodDeleted(pod *api.Pod) {
+	k.pod.Delete(pod)
+	delete(k.podTargets, cacheLookupKey(pod.GetNamespace(), pod.GetName()))
+}
+
+// PodGroup returns the key of the pod group the Resource routes to, or "" if it is backed by a Service
+func (r *Resource) PodGroup() string { return r.podGroup }
+
+func resourceFromPods(store *Cache, client unversioned.Interface, key string, pods []*api.Pod) *Resource {
+	var (
+		namespace, group = splitPodGroup(key)
+		leader           = pods[0]
+		port, _          = podPort(leader)
+		id               = GenResourceID(namespace, podGroupPrefix+group, intstr.FromInt(port))
+		r                = NewResource(id, "", store.annotationsFor(client, namespace, leader.ObjectMeta.Annotations))
+		scheme           = HTTP
+	)
+
+	logger.Debugf("[%v] Generate Resource from %d pod(s) in group %q", id, len(pods), key)
+	r.podGroup = key
+	r.created = leader.CreationTimestamp.Time
+	if sc, ok := r.GetAnnotation("scheme"); ok {
+		scheme = sc
+	}
+	for _, pod := range pods {
+		p, _ := podPort(pod)
+		if p != port {
+			logger.Warnf("[%v] Pod(%q) port %d differs from group port %d", id, pod.GetName(), p, port)
+		}
+		ref := &api.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod.GetName(), UID: pod.GetUID()}
+		s := &Server{
+			id:     GenServerIDFromRef(namespace, podGroupPrefix+group, ref, pod.Status.PodIP, p),
+			scheme: scheme,
+			ip:     pod.Status.PodIP,
+			port:   p,
+			weight: 1,
+			target: ref,
+		}
+		if !podReady(pod) {
+			s.weight, s.draining = 0, true
+		}
+		r.addServer(s)
+	}
+	store.drainServers(r)
+	store.mapPodGroup(key, id)
+	return r
+}
+
+// podGroup returns the routed pods of a group with an IP, oldest first
+func (k *Cache) podGroup(key string) []*api.Pod {
+	pods := make([]*api.Pod, 0, 1)
+	for _, obj := range k.pod.List() {
+		pod, ok := obj.(*api.Pod)
+		if !ok || pod.Status.PodIP == "" || !k.selector.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			continue
+		}
+		if gk, ok := podGroupKey(pod); ok && gk == key {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Sort(byAge(pods))
+	return pods
+}
+
+func (k *Cache) mapPodGroup(key, id string) {
+	if _, ok := k.podGroups[key]; !ok {
+		k.podGroups[key] = make(map[string]bool)
+	}
+	k.podGroups[key][id] = true
+}
+
+func (k *Cache) podGroupIDs(key string) []string {
+	ids := make([]string, 0, len(k.podGroups[key]))
+	for id := range k.podGroups[key] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (k *Cache) forgetPodGroup(key string) {
+	for id := range k.podGroups[key] {
+		for sid := range k.known[id] {
+			delete(k.drains, drainKey(id, sid))
+		}
+		delete(k.known, id)
+	}
+	delete(k.podGroups, key)
+}
+
+func podGroupKey(pod *api.Pod) (string, bool) {
+	if _, ok := podPort(pod); !ok {
+		return "", false
+	}
+	group := pod.GetName()
+	if val, ok := pod.ObjectMeta.Annotations[path.Join(Keyspace, PodGroupKey)]; ok && val != "" {
+		group = val
+	}
+	return string(cacheLookupKey(pod.GetNamespace(), group)), true
+}
+
+func splitPodGroup(key string) (string, string) {
+	bits := strings.SplitN(key, "/", 2)
+	if len(bits) < 2 {
+		return "", bits[0]
+	}
+	return bits[0], bits[1]
+}
+
+func podPort(pod *api.Pod) (int, bool) {
+	val, ok := pod.ObjectMeta.Annotations[path.Join(Keyspace, PodPortKey)]
+	if !ok {
+		return 0, false
+	}
+	port, er := strconv.Atoi(val)
+	if er != nil || port < 1 {
+		logger.Warnf("Invalid port %q on Pod(%q)", val, pod.GetName())
+		return 0, false
+	}
+	return port, true
+}
+
+func podReady(pod *api.Pod) bool {
+	if pod.ObjectMeta.DeletionTimestamp != nil {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == api.PodReady {
+			return c.Status == api.ConditionTrue
+		}
+	}
+	return false
+}
+
+type byAge []*api.Pod
+
+func (b byAge) Len() int      { return len(b) }
+func (b byAge) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byAge) Less(i, j int) bool {
+	if !b[i].CreationTimestamp.Equal(b[j].CreationTimestamp) {
+		return b[i].CreationTimestamp.Before(b[j].CreationTimestamp)
+	}
+	return b[i].GetName() < b[j].GetName()
+}
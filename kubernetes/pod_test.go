@@ -0,0 +1,75 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+func routedPod(name, ip string, age time.Duration, ready bool, annotations map[string]string) *api.Pod {
+	status := api.ConditionFalse
+	if ready {
+		status = api.ConditionTrue
+	}
+	pod := testPod(name, nil, annotations)
+	pod.CreationTimestamp = unversioned.NewTime(time.Now().Add(-age))
+	pod.Status = api.PodStatus{
+		PodIP:      ip,
+		Conditions: []api.PodCondition{{Type: api.PodReady, Status: status}},
+	}
+	return pod
+}
+
+func TestPodRoutes(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		an   = func(host string) map[string]string {
+			return map[string]string{"romulus/port": "9000", "romulus/group": "agents", "romulus/host": host}
+		}
+		a = routedPod("agent-a", "10.0.0.1", time.Hour, true, an("a.example.com"))
+		b = routedPod("agent-b", "10.0.0.2", time.Minute, true, an("b.example.com"))
+		n = routedPod("agent-c", "10.0.0.3", time.Minute, false, an("c.example.com"))
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	is.Empty(PodGroups(testPod("plain", nil, nil)), "pods without a port are not routed")
+	is.Equal([]string{"test/agents"}, PodGroups(a, b, n))
+	is.Equal([]string{"test/solo"}, PodGroups(routedPod("solo", "10.0.0.9", 0, true, map[string]string{"romulus/port": "80"})))
+
+	for _, pod := range []*api.Pod{b, n, a} {
+		c.PodAdded(pod)
+	}
+	list, removals := GenPodResources(c, nil, "test/agents")
+	is.Empty(removals)
+	must.Len(list, 1)
+	is.Equal("test.pod-agents.9000", list[0].ID())
+	is.Equal("test/agents", list[0].PodGroup())
+	is.Equal("Route(host(`a.example.com`))", list[0].Route.String(), "the oldest pod sets the route")
+	if is.Len(list[0].Servers(), 2, "pods that are not Ready are left out") {
+		is.Equal("10.0.0.1", list[0].Servers()[0].ip)
+		is.Equal(9000, list[0].Servers()[0].port)
+	}
+
+	c.PodDeleted(a)
+	c.PodDeleted(b)
+	list, _ = GenPodResources(c, nil, "test/agents")
+	if is.Len(list, 1) {
+		is.Empty(list[0].Servers())
+	}
+
+	c.PodDeleted(n)
+	list, removals = GenPodResources(c, nil, "test/agents")
+	is.Empty(list)
+	if is.Len(removals, 1) {
+		is.Equal("test.pod-agents.9000", removals[0].ID())
+	}
+	_, removals = GenPodResources(c, nil, "test/agents")
+	is.Empty(removals)
+}
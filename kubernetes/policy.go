@@ -0,0 +1,244 @@
+package kubernetes
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"github.com/bradfitz/slice"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+// HostPolicyKey is the ConfigMap key holding host ownership rules, in the format of ParseHostPolicy
+const HostPolicyKey = "host_policy"
+
+// AnyHost is the host of rejections of frontends without a host
+const AnyHost = "any host"
+
+// HostPolicyRules are the host ownership rules from the --host-policy file
+var HostPolicyRules HostPolicy
+
+// HostRule allows routes for hosts matching Pattern only in Namespaces
+type HostRule struct {
+	Pattern    string   `json:"pattern"`
+	Namespaces []string `json:"namespaces"`
+}
+
+// HostPolicy maps host patterns to the namespaces allowed to route them. The first rule
+// matching a host decides, hosts matching no rule are open to every namespace.
+type HostPolicy []HostRule
+
+// Rejection is a frontend left out of the loadbalancer because of the host policy
+type Rejection struct {
+	Frontend  string `json:"frontend"`
+	Namespace string `json:"namespace"`
+	Host      string `json:"host"`
+	Route     string `json:"route"`
+
+	resource *Resource
+}
+
+// ParseHostPolicy parses one rule per line, a host pattern (see path.Match, e.g. *.example.com)
+// and the namespaces allowed to route it, '*' for all:
+//
+//	api.example.com: team-a, ops
+//	*.team-b.example.com: team-b
+//
+// Empty lines and lines starting with # are skipped.
+func ParseHostPolicy(text string) (HostPolicy, error) {
+	var (
+		policy  = HostPolicy{}
+		scanner = bufio.NewScanner(strings.NewReader(text))
+	)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		bits := strings.SplitN(line, ":", 2)
+		if len(bits) < 2 {
+			return nil, fmt.Errorf("Host policy line %d: expected '<host pattern>: <namespaces>'", n)
+		}
+		rule := HostRule{
+			Pattern:    strings.TrimSpace(bits[0]),
+			Namespaces: strings.FieldsFunc(bits[1], isListSeparator),
+		}
+		if _, er := path.Match(rule.Pattern, ""); er != nil {
+			return nil, fmt.Errorf("Host policy line %d: bad pattern %q: %v", n, rule.Pattern, er)
+		}
+		if len(rule.Namespaces) < 1 {
+			return nil, fmt.Errorf("Host policy line %d: no namespaces for %q", n, rule.Pattern)
+		}
+		policy = append(policy, rule)
+	}
+	return policy, scanner.Err()
+}
+
+// LoadHostPolicy reads a host policy file
+func LoadHostPolicy(file string) (HostPolicy, error) {
+	b, er := ioutil.ReadFile(file)
+	if er != nil {
+		return nil, er
+	}
+	return ParseHostPolicy(string(b))
+}
+
+// Allows returns true if namespace may route the host part. Hosts are matched in lower case
+// without a trailing dot. A wildcard host also needs every rule for hosts under its domain to
+// allow the namespace. Regexp hosts may match any host, so they need a '*' rule allowing the
+// namespace.
+func (p HostPolicy) Allows(host *routePart, namespace string) bool {
+	if len(p) < 1 {
+		return true
+	}
+	if host.regex {
+		return p.allowsAnyHost(namespace)
+	}
+
+	value := normalHost(host.value)
+	if isWildcardHost(value) {
+		for _, rule := range p {
+			if rule.under(value) && !rule.allows(namespace) {
+				return false
+			}
+		}
+	}
+	for _, rule := range p {
+		if ok, _ := path.Match(normalHost(rule.Pattern), value); ok {
+			return rule.allows(namespace)
+		}
+	}
+	return true
+}
+
+// allowsAnyHost returns true if namespace may route routes matching any host, which needs a '*'
+// rule allowing it once there is a policy
+func (p HostPolicy) allowsAnyHost(namespace string) bool {
+	for _, rule := range p {
+		if rule.Pattern == "*" {
+			return rule.allows(namespace)
+		}
+	}
+	return len(p) < 1
+}
+
+// under is true if the rule matches hosts the wildcard host matches too
+func (h HostRule) under(wildcard string) bool {
+	var (
+		pattern = normalHost(h.Pattern)
+		domain  = strings.TrimPrefix(wildcard, "*")
+		label   = strings.TrimSuffix(pattern, domain)
+	)
+	if ok, _ := path.Match(pattern, wildcard); ok {
+		return true
+	}
+	return strings.HasSuffix(pattern, domain) && label != "" && !strings.Contains(label, ".")
+}
+
+func (h HostRule) allows(namespace string) bool {
+	for _, ns := range h.Namespaces {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// HostPolicy returns the host ownership rules, those of the ConfigMap before those of the file
+func (k *Cache) HostPolicy() HostPolicy {
+	policy := HostPolicy{}
+	for _, obj := range k.config.List() {
+		cm, ok := obj.(*extensions.ConfigMap)
+		if !ok {
+			continue
+		}
+		if text, ok := cm.Data[HostPolicyKey]; ok {
+			rules, er := ParseHostPolicy(text)
+			if er != nil {
+				logger.Warnf("Ignoring %s of ConfigMap(%q): %v", HostPolicyKey, cm.GetName(), er)
+			}
+			policy = append(policy, rules...)
+		}
+	}
+	return append(policy, HostPolicyRules...)
+}
+
+// Enforce returns the frontends of rsc the host policy allows and the rejections not reported
+// before. Rejected frontends do not take part in conflicts. Frontends without a host match any
+// host, so like regexp hosts they need a '*' rule allowing their namespace.
+func (x *RouteIndex) Enforce(policy HostPolicy, rsc *Resource) (allowed ResourceList, found []*Rejection) {
+	x.Lock()
+	defer x.Unlock()
+
+	known := make(map[string]bool)
+	for _, r := range x.rejections[rsc.ID()] {
+		known[r.Frontend+" "+r.Host] = true
+	}
+	rejections := make([]*Rejection, 0, 1)
+
+	for _, fr := range rsc.Frontends() {
+		denied := ""
+		if hosts := fr.Route.Hosts(); len(hosts) < 1 && !policy.allowsAnyHost(fr.Namespace()) {
+			denied = AnyHost
+		} else {
+			for _, host := range hosts {
+				if !policy.Allows(host, fr.Namespace()) {
+					denied = host.String()
+					break
+				}
+			}
+		}
+		if denied == "" {
+			allowed = append(allowed, fr)
+			continue
+		}
+
+		r := &Rejection{
+			Frontend:  fr.FrontendID(),
+			Namespace: fr.Namespace(),
+			Host:      denied,
+			Route:     fr.Route.String(),
+			resource:  fr,
+		}
+		rejections = append(rejections, r)
+		if !known[r.Frontend+" "+r.Host] {
+			found = append(found, r)
+		}
+	}
+
+	if len(rejections) > 0 {
+		x.rejections[rsc.ID()] = rejections
+	} else {
+		delete(x.rejections, rsc.ID())
+	}
+	return allowed, found
+}
+
+// Rejections returns the frontends currently rejected by the host policy
+func (x *RouteIndex) Rejections() []*Rejection {
+	x.RLock()
+	defer x.RUnlock()
+
+	list := make([]*Rejection, 0, len(x.rejections))
+	for _, rejections := range x.rejections {
+		list = append(list, rejections...)
+	}
+	slice.Sort(list, func(i, j int) bool {
+		return list[i].Frontend < list[j].Frontend
+	})
+	return list
+}
+
+// String describes the rejection for logs and Events
+func (r *Rejection) String() string {
+	return fmt.Sprintf("%s %v rejected, namespace %q may not route %s", r.Frontend, r.Route, r.Namespace, r.Host)
+}
+
+// RecordRejection creates a Warning Event about the rejection for the Service of the frontend
+func RecordRejection(client SuperClient, r *Rejection) {
+	recordEvent(client, r.resource, "RouteRejected", r.String())
+}
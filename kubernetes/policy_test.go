@@ -0,0 +1,141 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+func TestParseHostPolicy(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+	)
+
+	policy, er := ParseHostPolicy(`
+# owned hosts
+api.example.com: team-a, ops
+*.team-b.example.com: team-b
+*: *
+`)
+	must.NoError(er)
+	is.Equal(HostPolicy{
+		{Pattern: "api.example.com", Namespaces: []string{"team-a", "ops"}},
+		{Pattern: "*.team-b.example.com", Namespaces: []string{"team-b"}},
+		{Pattern: "*", Namespaces: []string{"*"}},
+	}, policy)
+
+	for _, text := range []string{"api.example.com", "api.example.com:", "[api: team-a"} {
+		_, er := ParseHostPolicy(text)
+		is.Error(er, text)
+	}
+}
+
+func TestHostPolicyAllows(te *testing.T) {
+	var (
+		is     = assert.New(te)
+		policy = HostPolicy{
+			{Pattern: "api.example.com", Namespaces: []string{"team-a", "ops"}},
+			{Pattern: "*.team-b.example.com", Namespaces: []string{"team-b"}},
+		}
+		tests = []struct {
+			policy    HostPolicy
+			host      string
+			namespace string
+			allowed   bool
+		}{
+			{nil, "api.example.com", "team-b", true},
+			{nil, "|.*|", "team-b", true},
+			{policy, "api.example.com", "team-a", true},
+			{policy, "api.example.com", "ops", true},
+			{policy, "api.example.com", "team-b", false},
+			{policy, "www.team-b.example.com", "team-b", true},
+			{policy, "www.team-b.example.com", "team-a", false},
+			{policy, "*.team-b.example.com", "team-a", false},
+			{policy, "www.example.com", "team-a", true},
+			{policy, "API.example.com", "team-b", false},
+			{policy, "api.example.com.", "team-b", false},
+			{policy, "Www.Team-B.example.com.", "team-b", true},
+			{policy, "*.example.com", "team-b", false},
+			{policy, "*.example.com", "ops", true},
+			{policy, "*.Example.com", "team-b", false},
+			{policy, "*.www.example.com", "team-b", true},
+			{append(policy, HostRule{Pattern: "*", Namespaces: []string{"team-a"}}), "*.example.com", "ops", false},
+			{policy, "|.*example.com|", "team-a", false},
+			{append(policy, HostRule{Pattern: "*", Namespaces: []string{"ops"}}), "|.*example.com|", "ops", true},
+		}
+	)
+
+	for _, t := range tests {
+		rt := &Route{}
+		is.NoError(rt.AddHost(t.host))
+		is.Equal(t.allowed, t.policy.Allows(rt.Hosts()[0], t.namespace), "%v %s %s", t.policy, t.host, t.namespace)
+	}
+}
+
+func TestEnforceHostPolicy(te *testing.T) {
+	var (
+		is     = assert.New(te)
+		c      = NewCache()
+		x      = NewRouteIndex()
+		policy = "api.example.com: team-a"
+		rsc    = routedResource("team-b", "api", time.Now(), map[string]string{
+			"romulus/host":         "www.example.com",
+			"romulus/route.1.host": "api.example.com",
+		})
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	c.config.Add(&extensions.ConfigMap{
+		ObjectMeta: api.ObjectMeta{Name: "romulus", Namespace: "kube-system"},
+		Data:       map[string]string{HostPolicyKey: policy},
+	})
+
+	allowed, found := x.Enforce(c.HostPolicy(), rsc)
+	if is.Len(allowed, 1) {
+		is.Equal("team-b.api.web", allowed[0].FrontendID())
+	}
+	if is.Len(found, 1) {
+		is.Equal("team-b.api.web.route-1", found[0].Frontend)
+		is.Equal("team-b", found[0].Namespace)
+	}
+	is.Len(x.Rejections(), 1)
+
+	_, found = x.Enforce(c.HostPolicy(), rsc)
+	is.Empty(found, "known rejections should not be reported again")
+
+	x.Release(rsc.ID())
+	is.Empty(x.Rejections())
+}
+
+func TestEnforceHostPolicyWithoutHost(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		x   = NewRouteIndex()
+		rsc = routedResource("team-b", "api", time.Now(), map[string]string{"romulus/prefix": "/api"})
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+
+	allowed, found := x.Enforce(nil, rsc)
+	is.Len(allowed, 1, "without a policy every route is allowed")
+	is.Empty(found)
+
+	allowed, found = x.Enforce(HostPolicy{{Pattern: "api.example.com", Namespaces: []string{"team-a"}}}, rsc)
+	is.Empty(allowed, "a route without a host matches the hosts of the policy too")
+	if is.Len(found, 1) {
+		is.Equal(AnyHost, found[0].Host)
+	}
+
+	allowed, _ = x.Enforce(HostPolicy{
+		{Pattern: "api.example.com", Namespaces: []string{"team-a"}},
+		{Pattern: "*", Namespaces: []string{"team-b"}},
+	}, rsc)
+	is.Len(allowed, 1, "a '*' rule allows routes without a host")
+}
@@ -0,0 +1,77 @@
+package kubernetes
+
+import (
+	"strconv"
+
+	"github.com/albertrdixon/gearbox/logger"
+)
+
+const (
+	// PriorityStep separates the precedence tiers of routes, see Route.Priority
+	PriorityStep = 1000
+	// PriorityTiers is the number of precedence tiers
+	PriorityTiers = 9
+)
+
+// Priority returns the precedence of the Resource route over overlapping routes, higher wins.
+// The priority annotation overrides the value computed from the route.
+func (r *Resource) Priority() int {
+	if val, ok := r.GetAnnotation(PriorityKey); ok {
+		p, er := strconv.Atoi(val)
+		if er == nil && p > 0 {
+			return p
+		}
+		logger.Warnf("[%v] Bad %s %q, using the route priority", r.id, PriorityKey, val)
+	}
+	return r.Route.Priority()
+}
+
+// Priority computes the precedence of the route. Hosts come first (exact host > wildcard
+// or regexp host > any host), then paths (exact path > prefix > catch-all). Each of those
+// tiers is PriorityStep wide, longer prefixes rank higher within their tier.
+func (r *Route) Priority() int {
+	var (
+		host, path int
+		length     int
+	)
+
+	for _, part := range r.parts {
+		switch part.kind {
+		case HostPart:
+			rank := 2
+			if part.regex || part.IsWildcard() {
+				rank = 1
+			}
+			if host == 0 || rank < host {
+				host = rank
+			}
+		case PathPart:
+			if !part.regex {
+				path, length = 2, len(part.value)
+			} else if path < 2 {
+				path, length = 1, len(part.value)
+			}
+		case PrefixPart:
+			if path < 2 {
+				path, length = 1, len(part.value)
+			}
+		}
+	}
+
+	if length >= PriorityStep {
+		length = PriorityStep - 1
+	}
+	return (host*3+path+1)*PriorityStep + length
+}
+
+// PriorityTier returns the precedence tier of a priority, from 0 to PriorityTiers-1
+func PriorityTier(priority int) int {
+	tier := priority/PriorityStep - 1
+	switch {
+	case tier < 0:
+		return 0
+	case tier >= PriorityTiers:
+		return PriorityTiers - 1
+	}
+	return tier
+}
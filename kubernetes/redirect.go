@@ -0,0 +1,122 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+)
+
+// RedirectExpr matches the request URL for Redirect.Replacement, ${1} is the scheme and ${2} the
+// path and query
+const RedirectExpr = `^(https?)://[^/]+(.*)$`
+
+// Redirect answers requests for its hosts with a redirect to the target
+type Redirect struct {
+	// Target is a host, keeping the scheme of the request, or a URL whose path is put
+	// in front of the request path
+	Target string
+	// Code is 301 or 302
+	Code int
+}
+
+// Redirect returns the redirect of a redirect frontend, see Frontends
+func (r *Resource) Redirect() (*Redirect, bool) {
+	return r.redirect, r.redirect != nil
+}
+
+// Permanent is true for a 301 redirect
+func (rd *Redirect) Permanent() bool {
+	return rd.Code == http.StatusMovedPermanently
+}
+
+// Replacement returns the replacement of RedirectExpr building the target URL
+func (rd *Redirect) Replacement() string {
+	if !strings.Contains(rd.Target, "://") {
+		return "${1}://" + rd.Target + "${2}"
+	}
+	return strings.TrimSuffix(rd.Target, "/") + "${2}"
+}
+
+func (rd *Redirect) String() string {
+	return fmt.Sprintf("Redirect(%d %s)", rd.Code, rd.Target)
+}
+
+// redirectFrontend returns the frontend for the redirect_host, redirect_to and redirect_code
+// annotations, matching the redirect hosts with frontend ID <resource ID>.redirect, or nil
+func (r *Resource) redirectFrontend() *Resource {
+	hosts, ok := r.GetAnnotation(RedirectHostKey)
+	if !ok {
+		return nil
+	}
+	target, ok := r.GetAnnotation(RedirectToKey)
+	if !ok {
+		logger.Warnf("[%v] %s needs %s, ignoring it", r.id, RedirectHostKey, RedirectToKey)
+		return nil
+	}
+
+	rd := &Redirect{Target: strings.TrimSpace(target), Code: http.StatusMovedPermanently}
+	if er := rd.validate(); er != nil {
+		logger.Warnf("[%v] Bad %s: %v", r.id, RedirectToKey, er)
+		return nil
+	}
+	if val, ok := r.GetAnnotation(RedirectCodeKey); ok {
+		code, er := strconv.Atoi(val)
+		if er != nil || (code != http.StatusMovedPermanently && code != http.StatusFound) {
+			logger.Warnf("[%v] %s should be 301 or 302, got %q", r.id, RedirectCodeKey, val)
+		} else {
+			rd.Code = code
+		}
+	}
+
+	rt := NewRoute(r.id, annotations{HostsKey: hosts})
+	for _, host := range rt.Hosts() {
+		if host.value == rd.host() {
+			logger.Warnf("[%v] %s %q redirects to itself, ignoring it", r.id, RedirectHostKey, host.value)
+			return nil
+		}
+	}
+	if rt.Empty() {
+		return nil
+	}
+
+	fr := *r
+	fr.Route = rt
+	fr.frontend = fmt.Sprintf("%s.redirect", r.id)
+	fr.routes = nil
+	fr.redirect = rd
+	return &fr
+}
+
+func (rd *Redirect) validate() error {
+	if rd.Target == "" {
+		return fmt.Errorf("empty target")
+	}
+	if !strings.Contains(rd.Target, "://") {
+		if strings.ContainsAny(rd.Target, "/?#$ ") {
+			return fmt.Errorf("%q is not a host, give a URL to redirect to a path", rd.Target)
+		}
+		return nil
+	}
+	u, er := url.Parse(rd.Target)
+	if er != nil {
+		return er
+	}
+	if u.Scheme != HTTP && u.Scheme != HTTPS {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" || u.RawQuery != "" || u.Fragment != "" || strings.Contains(rd.Target, "$") {
+		return fmt.Errorf("%q should be <scheme>://<host>[/<path>]", rd.Target)
+	}
+	return nil
+}
+
+func (rd *Redirect) host() string {
+	if u, er := url.Parse(rd.Target); er == nil && u.Host != "" {
+		return u.Host
+	}
+	return rd.Target
+}
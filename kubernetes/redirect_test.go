@@ -0,0 +1,57 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectFrontend(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			annotations map[string]string
+			route       string
+			replacement string
+			code        int
+		}{
+			{map[string]string{"redirect_host": "www.example.com", "redirect_to": "example.com"}, "Route(host(`www.example.com`))", "${1}://example.com${2}", 301},
+			{
+				map[string]string{"redirect_host": "old.com, *.old.com", "redirect_to": "https://example.com/legacy/", "redirect_code": "302"},
+				"Route((host(`*.old.com`) || host(`old.com`)))", "https://example.com/legacy${2}", 302,
+			},
+			{map[string]string{"redirect_host": "www.example.com", "redirect_to": "example.com", "redirect_code": "307"}, "Route(host(`www.example.com`))", "${1}://example.com${2}", 301},
+			{map[string]string{"redirect_host": "www.example.com"}, "", "", 0},
+			{map[string]string{"redirect_host": "www.example.com", "redirect_to": "example.com/path"}, "", "", 0},
+			{map[string]string{"redirect_host": "www.example.com", "redirect_to": "ftp://example.com"}, "", "", 0},
+			{map[string]string{"redirect_host": "example.com", "redirect_to": "https://example.com/new"}, "", "", 0},
+		}
+	)
+
+	for _, t := range tests {
+		var (
+			r         = NewResource("test.app.web", "web", t.annotations)
+			frontends = r.Frontends()
+		)
+		if t.route == "" {
+			is.Len(frontends, 1, "%v", t.annotations)
+			_, ok := frontends[0].Redirect()
+			is.False(ok)
+			continue
+		}
+
+		if !is.Len(frontends, 1, "an empty own route is left out for the redirect") {
+			continue
+		}
+		fr := frontends[0]
+		rd, ok := fr.Redirect()
+		if is.True(ok) {
+			is.Equal("test.app.web.redirect", fr.FrontendID())
+			is.Equal("test.app.web", fr.ID())
+			is.Equal(t.route, fr.Route.String())
+			is.Equal(t.replacement, rd.Replacement())
+			is.Equal(t.code, rd.Code)
+			is.Equal(t.code == 301, rd.Permanent())
+		}
+	}
+}
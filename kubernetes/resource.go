@@ -7,11 +7,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/endpoints"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/client/unversioned"
+	utilerrors "k8s.io/kubernetes/pkg/util/errors"
 
 	"github.com/albertrdixon/gearbox/logger"
 	"github.com/albertrdixon/gearbox/url"
@@ -27,14 +29,14 @@ func NewResource(id, namespace string, anno annotations) *Resource {
 	for key, value := range anno {
 		if strings.HasPrefix(key, Keyspace) {
 			bits := strings.SplitN(path.Base(key), ".", 2)
-			if len(bits) == 2 && namespace == "" && bits[0] != "middleware" {
+			if len(bits) == 2 && namespace == "" && !isResourceWide(bits[0]) {
 				continue
 			}
 			switch len(bits) {
 			case 2:
 				if bits[0] == namespace {
 					an[bits[1]] = value
-				} else if bits[0] == "middleware" {
+				} else if isResourceWide(bits[0]) {
 					an[strings.Join(bits, ".")] = value
 				}
 			case 1:
@@ -55,6 +57,7 @@ func NewResource(id, namespace string, anno annotations) *Resource {
 	return &Resource{
 		id:          id,
 		Route:       NewRoute(id, an),
+		routes:      alternativeRoutes(id, an),
 		annotations: an,
 		servers:     make([]*Server, 0, 1),
 		websocket:   websocket,
@@ -72,16 +75,23 @@ func NewRoute(id string, anno annotations) *Route {
 	for key, val := range anno {
 		switch key {
 		case HeadersKey:
-			vals := strings.Fields(strings.Replace(val, ";", "", -1))
-			for _, v := range vals {
-				bits := strings.SplitN(v, "=", 2)
-				if len(bits) < 2 {
-					continue
-				}
+			for _, bits := range namedValues(val) {
 				if er := rt.AddHeader(bits[0], bits[1]); er != nil {
 					logger.Warnf("[%v] Failed to add header(%q) matcher: %v", id, bits[0], er)
 				}
 			}
+		case QueryKey:
+			for _, bits := range namedValues(val) {
+				if er := rt.AddQuery(bits[0], bits[1]); er != nil {
+					logger.Warnf("[%v] Failed to add query(%q) matcher: %v", id, bits[0], er)
+				}
+			}
+		case CookiesKey:
+			for _, bits := range namedValues(val) {
+				if er := rt.AddCookie(bits[0], bits[1]); er != nil {
+					logger.Warnf("[%v] Failed to add cookie(%q) matcher: %v", id, bits[0], er)
+				}
+			}
 		case MethodsKey:
 			vals := strings.Fields(strings.Replace(val, ";", "", -1))
 			for _, v := range vals {
@@ -93,6 +103,12 @@ func NewRoute(id string, anno annotations) *Route {
 			if er := rt.AddHost(val); er != nil {
 				logger.Warnf("[%v] Failed to add host matcher: %v", id, er)
 			}
+		case HostsKey:
+			for _, host := range strings.FieldsFunc(val, isListSeparator) {
+				if er := rt.AddHost(host); er != nil {
+					logger.Warnf("[%v] Failed to add host matcher: %v", id, er)
+				}
+			}
 		case PathKey:
 			if er := rt.AddPath(val); er != nil {
 				logger.Warnf("[%v] Failed to add patch matcher: %v", id, er)
@@ -128,6 +144,9 @@ func GenResources(store *Cache, client SuperClient, obj interface{}) (ResourceLi
 	case *api.Endpoints:
 		list = resourcesFromEndpoints(store, client, t)
 		po = Endpoints(*t)
+	case *api.Node, *api.Namespace, *extensions.ConfigMap:
+		// Nodes, Namespaces and the ConfigMap only change the Resources depending on them, see GenDependentResources
+		return list, nil
 	}
 	Sort(list, ByID)
 	logger.Debugf("Resources from %v: %v", po, list)
@@ -138,52 +157,64 @@ func resourcesFromIngress(store *Cache, client unversioned.Interface, in *extens
 	var (
 		list ResourceList = make([]*Resource, 0, 1)
 		i    Ingress      = Ingress(*in)
-
-		namespace = in.GetNamespace()
 	)
 
 	logger.Debugf("Generate Resources from %v", i)
 	if in.Spec.Backend != nil {
-		name := in.Spec.Backend.ServiceName
+		namespace, name := ingressBackendService(in, *in.Spec.Backend)
+		if !store.ingressPermitted(client, in, namespace) {
+			goto Rules
+		}
 		svc, er := store.GetService(client, namespace, name)
 		if er != nil {
 			logger.Warnf(er.Error())
 			goto Rules
 		}
 
-		store.MapServiceToIngress(namespace, svc.GetName(), in.GetName())
+		store.mapServiceToIngress(namespace, svc.GetName(), in.GetNamespace(), in.GetName())
 		port, ok := GetServicePort(svc, in.Spec.Backend.ServicePort)
 		if !ok {
 			goto Rules
 		}
 
 		id := GenResourceID(namespace, name, intstrFromPort(port.Name, port.Port))
-		r := NewResource(id, port.Name, svc.ObjectMeta.Annotations)
+		r := NewResource(id, port.Name, store.annotationsFor(client, namespace, svc.ObjectMeta.Annotations))
 		r.Route.parts = nil
+		applyIngressAnnotations(r, in, name)
 		en, _ := store.GetEndpoints(client, namespace, name)
-		AddServers(r, svc, en, port)
+		AddServers(store, client, r, svc, en, port)
 
 		list = append(list, r)
 	}
 
 Rules:
+	seen := make(map[string]*Resource)
 	for _, rule := range in.Spec.Rules {
 		for _, path := range rule.HTTP.Paths {
-			name := path.Backend.ServiceName
+			namespace, name := ingressBackendService(in, path.Backend)
+			if !store.ingressPermitted(client, in, namespace) {
+				continue
+			}
 			svc, er := store.GetService(client, namespace, name)
 			if er != nil {
 				continue
 			}
-			store.MapServiceToIngress(namespace, svc.GetName(), in.GetName())
+			store.mapServiceToIngress(namespace, svc.GetName(), in.GetNamespace(), in.GetName())
 			port, ok := GetServicePort(svc, path.Backend.ServicePort)
 			if !ok {
 				continue
 			}
 
 			id := GenResourceID(namespace, name, intstrFromPort(port.Name, port.Port))
-			r := NewResource(id, port.Name, svc.ObjectMeta.Annotations)
+			key := strings.Join([]string{id, path.Path}, " ")
+			if r, ok := seen[key]; ok {
+				addIngressHost(r.Route, rule.Host)
+				continue
+			}
+			r := NewResource(id, port.Name, store.annotationsFor(client, namespace, svc.ObjectMeta.Annotations))
+			applyIngressAnnotations(r, in, name)
 			en, _ := store.GetEndpoints(client, namespace, name)
-			AddServers(r, svc, en, port)
+			AddServers(store, client, r, svc, en, port)
 
 			if rule.Host != "" {
 				r.Route.delete(HostPart)
@@ -193,6 +224,7 @@ Rules:
 				r.Route.delete(PathPart)
 				r.Route.AddPath(path.Path)
 			}
+			seen[key] = r
 			list = append(list, r)
 		}
 	}
@@ -217,11 +249,12 @@ func resourcesFromService(store *Cache, client SuperClient, svc *api.Service) Re
 
 	for _, port := range svc.Spec.Ports {
 		id := GenResourceID(namespace, name, intstrFromPort(port.Name, port.Port))
-		r := NewResource(id, port.Name, svc.ObjectMeta.Annotations)
-		if in, er := store.GetIngress(client, namespace, name); er == nil {
-			routePartsFromIngress(r.Route, in, svc.GetName(), port)
+		r := NewResource(id, port.Name, store.annotationsFor(client, namespace, svc.ObjectMeta.Annotations))
+		if in, ok := store.ingressFor(client, svc); ok {
+			routePartsFromIngress(r.Route, in, namespace, name, port)
+			applyIngressAnnotations(r, in, name)
 		}
-		AddServers(r, svc, en, port)
+		AddServers(store, client, r, svc, en, port)
 
 		list = append(list, r)
 	}
@@ -247,11 +280,12 @@ func resourcesFromEndpoints(store *Cache, client SuperClient, en *api.Endpoints)
 
 	for _, port := range svc.Spec.Ports {
 		id := GenResourceID(namespace, name, intstrFromPort(port.Name, port.Port))
-		r := NewResource(id, port.Name, svc.ObjectMeta.Annotations)
-		if in, er := store.GetIngress(client, namespace, name); er == nil {
-			routePartsFromIngress(r.Route, in, svc.GetName(), port)
+		r := NewResource(id, port.Name, store.annotationsFor(client, namespace, svc.ObjectMeta.Annotations))
+		if in, ok := store.ingressFor(client, svc); ok {
+			routePartsFromIngress(r.Route, in, namespace, name, port)
+			applyIngressAnnotations(r, in, name)
 		}
-		AddServers(r, svc, en, port)
+		AddServers(store, client, r, svc, en, port)
 
 		list = append(list, r)
 	}
@@ -259,11 +293,40 @@ func resourcesFromEndpoints(store *Cache, client SuperClient, en *api.Endpoints)
 	return list
 }
 
-func AddServers(rsc *Resource, svc *api.Service, en *api.Endpoints, port api.ServicePort) {
-	if en != nil {
-		addServersFromEndpoints(rsc, en, port)
+func AddServers(store *Cache, client unversioned.Interface, rsc *Resource, svc *api.Service, en *api.Endpoints, port api.ServicePort) {
+	rsc.service = cacheLookupKey(svc.GetNamespace(), svc.GetName())
+	rsc.created = svc.CreationTimestamp.Time
+	rsc.affinity = hasSessionAffinity(svc)
+	if rsc.IsExternal(svc) {
+		addServersFromExternal(rsc, svc, port)
+	} else if rsc.UsesNodePorts() {
+		addServersFromNodes(store, rsc, svc, port)
+	} else if rsc.IsSplit() {
+		addServersFromSplit(store, client, rsc, svc.GetNamespace(), port)
+	} else if en != nil {
+		if er := addServersFromEndpoints(rsc, en, port); er != nil {
+			logger.Warnf("[%v] Invalid %v: %v", rsc.id, Endpoints(*en), er)
+		}
+		weighServers(store, client, rsc)
 	}
+	store.drainServers(rsc)
 	if rsc.NoServers() {
+		if rsc.IsExternal(svc) {
+			logger.Warnf("[%v] No servers added from external hosts", rsc.id)
+			return
+		}
+		if rsc.UsesNodePorts() {
+			logger.Warnf("[%v] No servers added from Nodes", rsc.id)
+			return
+		}
+		if resp, ok := rsc.NoEndpointsResponse(); ok {
+			logger.Warnf("[%v] No servers added from Endpoints, answering with %v", rsc.id, resp)
+			return
+		}
+		if !rsc.ServiceFallback() {
+			logger.Warnf("[%v] No servers added from Endpoints and Service fallback is disabled", rsc.id)
+			return
+		}
 		logger.Warnf("[%v] No servers added from Endpoints, falling back to Service", rsc.id)
 		addServersFromService(rsc, svc, port)
 	}
@@ -297,10 +360,12 @@ func addServersFromService(r *Resource, svc *api.Service, p api.ServicePort) {
 	}
 }
 
-func addServersFromEndpoints(r *Resource, en *api.Endpoints, p api.ServicePort) {
+// addServersFromEndpoints adds the servers of the Endpoints subsets serving p. Invalid subsets are
+// left out and their errors returned together.
+func addServersFromEndpoints(r *Resource, en *api.Endpoints, p api.ServicePort) error {
 	// Quick path, no subsets
 	if len(en.Subsets) < 1 {
-		return
+		return nil
 	}
 
 	var (
@@ -311,64 +376,102 @@ func addServersFromEndpoints(r *Resource, en *api.Endpoints, p api.ServicePort)
 	)
 
 	logger.Debugf("[%v] Adding Servers from %v", r.id, end)
+	found := false
+	errs := make([]error, 0)
 	for _, sub := range subs {
-		logger.Debugf("[%v] Subset(Ports=%+v, Addrs=%+v)", r.id, sub.Ports, sub.Addresses)
-		for _, port := range sub.Ports {
-			if !matchPort(p, port) {
-				continue
-			}
-
+		logger.Debugf("[%v] Subset(Ports=%+v, Addrs=%+v, NotReadyAddrs=%+v)", r.id, sub.Ports, sub.Addresses, sub.NotReadyAddresses)
+		ports, er := endpointPorts(p, sub)
+		if er != nil {
+			errs = append(errs, er)
+			continue
+		}
+		for _, port := range ports {
+			found = true
 			logger.Debugf(`[%v] Found Port("%d") in %v`, r.id, p.Port, end)
+			// scheme := string(port.Protocol)
+			scheme := HTTP
+			if sc, ok := r.GetAnnotation("scheme"); ok {
+				scheme = sc
+			}
 			for _, addr := range sub.Addresses {
-				id := GenServerID(namespace, name, addr.IP, port.Port)
-				// scheme := string(port.Protocol)
-				scheme := HTTP
-				if sc, ok := r.GetAnnotation("scheme"); ok {
-					scheme = sc
-				}
-				r.AddServer(id, scheme, addr.IP, port.Port)
+				id := GenServerIDFromRef(namespace, name, addr.TargetRef, addr.IP, port.Port)
+				r.addServer(&Server{id: id, scheme: scheme, ip: addr.IP, port: port.Port, weight: 1, target: targetRef(addr)})
+			}
+			for _, addr := range sub.NotReadyAddresses {
+				id := GenServerIDFromRef(namespace, name, addr.TargetRef, addr.IP, port.Port)
+				r.addServer(&Server{id: id, scheme: scheme, ip: addr.IP, port: port.Port, draining: true, target: targetRef(addr)})
 			}
 		}
 	}
+	if !found && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("no port serves Service port %v", intstrFromPort(p.Name, p.Port)))
+	}
+	return utilerrors.NewAggregate(errs)
 }
 
-func routePartsFromIngress(rt *Route, ing *extensions.Ingress, name string, port api.ServicePort) {
+func routePartsFromIngress(rt *Route, ing *extensions.Ingress, namespace, name string, port api.ServicePort) {
 	if ing.Spec.Backend != nil {
-		if matchIngressBackend(name, port, *ing.Spec.Backend) {
+		if matchIngressBackend(ing, namespace, name, port, *ing.Spec.Backend) {
 			rt.parts = nil
 			return
 		}
 	}
 
+	var (
+		matched bool
+		first   string
+	)
 	for _, rule := range ing.Spec.Rules {
 		for _, path := range rule.HTTP.Paths {
-			if matchIngressBackend(name, port, path.Backend) {
-				if rule.Host != "" {
-					rt.delete(HostPart)
-					rt.AddHost(rule.Host)
-				}
-				if path.Path != "" {
-					rt.delete(PathPart)
-					rt.AddPath(path.Path)
+			if !matchIngressBackend(ing, namespace, name, port, path.Backend) {
+				continue
+			}
+			if matched {
+				if path.Path == first {
+					addIngressHost(rt, rule.Host)
 				}
-				return
+				continue
+			}
+
+			matched, first = true, path.Path
+			if rule.Host != "" {
+				rt.delete(HostPart)
+				rt.AddHost(rule.Host)
+			}
+			if path.Path != "" {
+				rt.delete(PathPart)
+				rt.AddPath(path.Path)
 			}
 		}
 	}
 }
 
+// addIngressHost adds the host of another Ingress rule sending the same path to the same backend.
+// A rule without host matches any host, so it drops the hosts of the route.
+func addIngressHost(rt *Route, host string) {
+	switch {
+	case host == "":
+		rt.delete(HostPart)
+	case len(rt.Hosts()) > 0 && !rt.hasHost(host):
+		rt.AddHost(host)
+	}
+}
+
 func (r *Resource) AddServer(id, scheme, ip string, port int) {
+	r.AddWeightedServer(id, scheme, ip, port, 1)
+}
+
+// AddWeightedServer adds a server getting weight shares of the traffic
+func (r *Resource) AddWeightedServer(id, scheme, ip string, port, weight int) {
+	r.addServer(&Server{id: id, scheme: scheme, ip: ip, port: port, weight: weight})
+}
+
+func (r *Resource) addServer(server *Server) {
 	if r.servers == nil {
 		r.servers = make([]*Server, 0, 1)
 	}
 
-	server := &Server{
-		id:        id,
-		scheme:    scheme,
-		ip:        ip,
-		port:      port,
-		websocket: (scheme == "ws" || scheme == "wss"),
-	}
+	server.websocket = (server.scheme == "ws" || server.scheme == "wss")
 	logger.Debugf("[%v] Adding %v", r.id, server)
 	r.servers = append(r.servers, server)
 }
@@ -377,6 +480,57 @@ func (r *Resource) ID() string          { return r.id }
 func (r *Resource) Servers() ServerList { return r.servers }
 func (r *Resource) IsWebsocket() bool   { return r.websocket }
 
+// Service returns the namespace and name of the Service backing the Resource
+func (r *Resource) Service() (namespace, name string) {
+	bits := strings.SplitN(string(r.service), "/", 2)
+	if len(bits) < 2 {
+		return "", bits[0]
+	}
+	return bits[0], bits[1]
+}
+
+// Namespace returns the namespace of the Resource, the first part of its ID
+func (r *Resource) Namespace() string {
+	if namespace, _ := r.Service(); namespace != "" {
+		return namespace
+	}
+	return strings.SplitN(r.id, ".", 2)[0]
+}
+
+// DrainPeriod returns how long servers leaving the Resource are kept with zero weight
+func (r *Resource) DrainPeriod() time.Duration {
+	val, ok := r.GetAnnotation(DrainPeriodKey)
+	if !ok {
+		return 0
+	}
+	d, er := time.ParseDuration(val)
+	if er != nil {
+		logger.Warnf("[%v] Failed to parse drain period: %v", r.id, er)
+		return 0
+	}
+	return d
+}
+
+// RefreshIn returns the time after which the Resource should be generated again, e.g. to remove
+// draining servers or re-resolve external hosts, or 0 if it does not need to be
+func (r *Resource) RefreshIn() time.Duration { return r.refreshIn }
+
+func (r *Resource) refreshAfter(d time.Duration) {
+	if r.refreshIn == 0 || d < r.refreshIn {
+		r.refreshIn = d
+	}
+}
+
+// ServiceFallback returns true if the Resource may fall back to the Service IPs when it has no Endpoints
+func (r *Resource) ServiceFallback() bool {
+	if val, ok := r.GetAnnotation(ServiceFallbackKey); ok {
+		if b, er := strconv.ParseBool(val); er == nil {
+			return b
+		}
+	}
+	return ServiceFallback
+}
+
 func (r *Resource) GetAnnotations(expr string) (map[string]string, error) {
 	var matches = make(map[string]string)
 	rgx, er := regexp.Compile(expr)
@@ -413,6 +567,8 @@ func (s *Server) URL() *url.URL {
 
 func (s *Server) ID() string        { return s.id }
 func (s *Server) IsWebsocket() bool { return s.websocket }
+func (s *Server) IsDraining() bool  { return s.draining }
+func (s *Server) Weight() int       { return s.weight }
 
 func (r *Route) Empty() bool {
 	return r.parts == nil || len(r.parts) < 1
@@ -423,6 +579,36 @@ func (r *Route) AddHost(host string) error {
 	return r.add(part, host)
 }
 
+// Hosts returns the hosts the Route matches, any of them matches a request
+func (r *Route) Hosts() []*routePart {
+	hosts := make([]*routePart, 0, 1)
+	for _, part := range r.parts {
+		if part.kind == HostPart {
+			hosts = append(hosts, part)
+		}
+	}
+	return hosts
+}
+
+func (r *Route) hasHost(host string) bool {
+	for _, part := range r.Hosts() {
+		if part.value == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Prefix returns the path prefix of the route, empty if it has none or a regexp prefix
+func (r *Route) Prefix() string {
+	for _, part := range r.parts {
+		if part.kind == PrefixPart && !part.regex {
+			return part.value
+		}
+	}
+	return ""
+}
+
 func (r *Route) AddPath(path string) error {
 	part := &routePart{kind: PathPart, value: path}
 	return r.add(part, path)
@@ -433,6 +619,16 @@ func (r *Route) AddHeader(header, value string) error {
 	return r.add(part, value)
 }
 
+func (r *Route) AddQuery(param, value string) error {
+	part := &routePart{kind: QueryPart, header: param, value: value}
+	return r.add(part, value)
+}
+
+func (r *Route) AddCookie(cookie, value string) error {
+	part := &routePart{kind: CookiePart, header: cookie, value: value}
+	return r.add(part, value)
+}
+
 func (r *Route) AddMethod(method string) error {
 	part := &routePart{kind: MethodPart, value: method}
 	return r.add(part, method)
@@ -480,6 +676,20 @@ func (r *routePart) Value() string  { return r.value }
 func (r *routePart) Header() string { return r.header }
 func (r *routePart) IsRegex() bool  { return r.regex }
 
+// CookieRegexp matches a Cookie header carrying the cookie part
+func (r *routePart) CookieRegexp() string {
+	val := regexp.QuoteMeta(r.value)
+	if r.regex {
+		val = fmt.Sprintf("(?:%s)", r.value)
+	}
+	return fmt.Sprintf(`(^|;\s*)%s=%s(;|$)`, regexp.QuoteMeta(r.header), val)
+}
+
+// IsWildcard is true for hosts like *.example.com, the * matches one DNS label
+func (r *routePart) IsWildcard() bool {
+	return r.kind == HostPart && !r.regex && isWildcardHost(r.value)
+}
+
 func (r ResourceList) Map() map[string]*Resource {
 	m := make(map[string]*Resource, len(r))
 	for i := range r {
@@ -487,19 +697,3 @@ func (r ResourceList) Map() map[string]*Resource {
 	}
 	return m
 }
-
-// func (r ResourceList) Eql(l ResourceList) bool {
-// 	if len(r) != len(l) {
-// 		return false
-// 	}
-
-// 	Sort(r, ByID)
-// 	Sort(l, ByID)
-// 	for i := range l {
-
-// 	}
-// }
-
-// func (r *Resource) Eql(o *Resource) bool {
-// 	return r.id == o.id && r.uid == o.uid && r.Route.String() == o.Route.String()
-// }
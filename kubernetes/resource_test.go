@@ -7,6 +7,7 @@ import (
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/util/intstr"
 
 	"github.com/albertrdixon/gearbox/logger"
 	"github.com/stretchr/testify/assert"
@@ -23,6 +24,11 @@ func TestRoute(te *testing.T) {
 			{"Route()", map[string]string{}},
 			{"Route(host(`abc`) && prefix(`/f`))", map[string]string{"host": "abc", "prefix": "/f"}},
 			{"Route(method(`GET`) && method(`POST`))", map[string]string{"methods": "get; post"}},
+			{"Route((host(`a.com`) || host(`b.com`)))", map[string]string{"hosts": "b.com, a.com"}},
+			{
+				"Route(cookie(`session`, `abc`) && queryRegexp(`v`, `[12]`))",
+				map[string]string{"query": "v=|[12]|", "cookies": "session=abc"},
+			},
 			{
 				"Route(header(`X-Foo`, `Bar`) && headerRegexp(`X-Bif`, `Baz.*`))",
 				map[string]string{"headers": "X-Foo=Bar; X-Bif=|Baz.*|"},
@@ -107,3 +113,107 @@ func TestGenResources(te *testing.T) {
 		}
 	}
 }
+
+func TestIngressHostAliases(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		in   = testIngress("test", "aliases", nil, "api")
+		svc  = testService("api", nil)
+		en   = testEndpoints("api", "10.0.0.1")
+	)
+
+	alias := in.Spec.Rules[0]
+	alias.Host = "example.com"
+	in.Spec.Rules = append(in.Spec.Rules, alias)
+	c.service.Add(svc)
+	c.endpoints.Add(en)
+	c.ingress.Add(in)
+
+	list, er := GenResources(c, nil, in)
+	must.NoError(er)
+	if is.Len(list, 1, "rules with the same backend and path should share a resource") {
+		is.Equal("Route((host(`example.com`) || host(`www.example.com`)) && path(`/api`))", list[0].Route.String())
+	}
+
+	list, er = GenResources(c, nil, svc)
+	must.NoError(er)
+	if is.Len(list, 1) {
+		is.Equal("Route((host(`example.com`) || host(`www.example.com`)) && path(`/api`))", list[0].Route.String())
+	}
+}
+
+func TestEndpointPorts(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		named = func(name string, target intstr.IntOrString) api.ServicePort {
+			return api.ServicePort{Name: name, Port: 80, TargetPort: target}
+		}
+		tests = []struct {
+			port     api.ServicePort
+			ports    []api.EndpointPort
+			expected []int
+			invalid  bool
+		}{
+			{named("web", intstr.FromString("http")), []api.EndpointPort{{Name: "web", Port: 8080}, {Name: "admin", Port: 9090}}, []int{8080}, false},
+			{named("admin", intstr.FromInt(9090)), []api.EndpointPort{{Name: "web", Port: 8080}}, []int{}, false},
+			{named("web", intstr.FromString("http")), []api.EndpointPort{{Name: "http", Port: 8081}}, []int{}, false},
+			{named("", intstr.FromInt(8080)), []api.EndpointPort{{Port: 8080}}, []int{8080}, false},
+			{named("", intstr.FromInt(0)), []api.EndpointPort{{Port: 80}}, []int{80}, false},
+			{named("", intstr.FromInt(8080)), []api.EndpointPort{{Port: 9090}}, []int{}, true},
+			{named("", intstr.FromInt(8080)), []api.EndpointPort{{Port: 9090}, {Port: 8080}}, []int{8080}, false},
+			{named("", intstr.FromString("http")), []api.EndpointPort{{Port: 9090}, {Port: 8080}}, []int{}, true},
+			{named("web", intstr.FromString("http")), []api.EndpointPort{{Port: 9090}, {Name: "admin", Port: 8080}}, []int{}, true},
+		}
+	)
+
+	for i, t := range tests {
+		ports, er := endpointPorts(t.port, api.EndpointSubset{Ports: t.ports})
+		got := []int{}
+		for _, p := range ports {
+			got = append(got, p.Port)
+		}
+		is.Equal(t.expected, got, "test %d", i)
+		is.Equal(t.invalid, er != nil, "test %d: %v", i, er)
+	}
+}
+
+func TestAddServersFromEndpointsInvalidSubset(te *testing.T) {
+	var (
+		is = assert.New(te)
+		r  = NewResource("test.web.web", "web", nil)
+		en = testEndpoints("web", "10.0.0.1")
+	)
+
+	en.Subsets = append(en.Subsets, api.EndpointSubset{
+		Addresses: []api.EndpointAddress{{IP: "10.0.0.2"}},
+		Ports:     []api.EndpointPort{{Port: 9090}, {Name: "admin", Port: 8081}},
+	})
+	er := addServersFromEndpoints(r, en, api.ServicePort{Name: "web", Port: 80})
+	is.Error(er)
+	if is.Len(r.Servers(), 1, "the invalid subset is left out") {
+		is.Equal("10.0.0.1", r.Servers()[0].ip)
+	}
+
+	r = NewResource("test.web.web", "web", nil)
+	is.Error(addServersFromEndpoints(r, testEndpoints("web", "10.0.0.1"), api.ServicePort{Name: "admin", Port: 81}))
+	is.Empty(r.Servers())
+}
+
+func TestGenServerIDFromRef(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		ref = &api.ObjectReference{Kind: "Pod", Name: "web-1", UID: "0b6a6e4c-1234-11e6-8c3e-42010af00002"}
+	)
+
+	is.Equal("ns.svc.web-1-80-0b6a6e4c", GenServerIDFromRef("ns", "svc", ref, "10.0.0.1", 80))
+	is.NotEqual(GenServerIDFromRef("ns", "svc", ref, "10.0.0.1", 80), GenServerIDFromRef("ns", "svc", ref, "10.0.0.1", 8080), "ports of one Pod need their own IDs")
+	is.Equal(GenServerID("ns", "svc", "10.0.0.1", 80), GenServerIDFromRef("ns", "svc", nil, "10.0.0.1", 80))
+
+	reused := &api.ObjectReference{Kind: "Pod", Name: "web-1", UID: "7f9e2d1a-1234-11e6-8c3e-42010af00002"}
+	is.NotEqual(GenServerIDFromRef("ns", "svc", ref, "10.0.0.1", 80), GenServerIDFromRef("ns", "svc", reused, "10.0.0.1", 80))
+
+	s := Server{scheme: HTTP, ip: "10.0.0.1", port: 80, target: ref, draining: true}
+	is.Equal(`Server(url="http://10.0.0.1:80", pod="web-1", draining)`, s.String())
+}
@@ -0,0 +1,52 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+)
+
+// Response is a static response the loadbalancer answers with instead of a backend
+type Response struct {
+	Code int
+	Body string
+}
+
+// NoEndpointsResponse returns the response for the frontends of a Resource without servers, given
+// as '<status code> [<body>]', e.g. '503 Down for maintenance'. While it is set the Resource does
+// not fall back to the Service IPs, and once servers come back the response goes away.
+func (r *Resource) NoEndpointsResponse() (*Response, bool) {
+	if !r.NoServers() {
+		return nil, false
+	}
+	val, ok := r.GetAnnotation(NoEndpointsKey)
+	if !ok {
+		return nil, false
+	}
+	resp, er := parseResponse(val)
+	if er != nil {
+		logger.Warnf("[%v] Bad %s: %v", r.id, NoEndpointsKey, er)
+		return nil, false
+	}
+	return resp, true
+}
+
+func parseResponse(val string) (*Response, error) {
+	bits := strings.SplitN(strings.TrimSpace(val), " ", 2)
+	code, er := strconv.Atoi(bits[0])
+	if er != nil || code < 200 || code > 599 {
+		return nil, fmt.Errorf("%q should start with a status code", val)
+	}
+	resp := &Response{Code: code, Body: http.StatusText(code)}
+	if len(bits) == 2 {
+		resp.Body = strings.TrimSpace(bits[1])
+	}
+	return resp, nil
+}
+
+func (r *Response) String() string {
+	return fmt.Sprintf("Response(%d %q)", r.Code, r.Body)
+}
@@ -0,0 +1,42 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoEndpointsResponse(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			value string
+			ok    bool
+			code  int
+			body  string
+		}{
+			{"503 Down for maintenance, back soon", true, 503, "Down for maintenance, back soon"},
+			{" 503 ", true, 503, "Service Unavailable"},
+			{"200 OK", true, 200, "OK"},
+			{"maintenance", false, 0, ""},
+			{"700 Nope", false, 0, ""},
+		}
+	)
+
+	for _, t := range tests {
+		r := NewResource("test.app.web", "web", map[string]string{"no_endpoints_response": t.value})
+		resp, ok := r.NoEndpointsResponse()
+		if !is.Equal(t.ok, ok, t.value) || !ok {
+			continue
+		}
+		is.Equal(t.code, resp.Code)
+		is.Equal(t.body, resp.Body)
+
+		r.AddServer("test.app.web.1", HTTP, "10.0.0.1", 80)
+		_, ok = r.NoEndpointsResponse()
+		is.False(ok, "the response goes away with servers")
+	}
+
+	_, ok := NewResource("test.app.web", "web", nil).NoEndpointsResponse()
+	is.False(ok)
+}
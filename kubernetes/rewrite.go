@@ -0,0 +1,46 @@
+package kubernetes
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+)
+
+// StripPrefix returns the route prefix to strip from requests before they reach the servers.
+// It follows the prefix of the route, so it is only set for routes with a plain prefix.
+func (r *Resource) StripPrefix() (string, bool) {
+	val, ok := r.GetAnnotation(StripPrefixKey)
+	if !ok {
+		return "", false
+	}
+	if b, er := strconv.ParseBool(val); er != nil || !b {
+		return "", false
+	}
+	pre := r.Route.Prefix()
+	if pre == "" {
+		logger.Warnf("[%v] %s needs a route prefix, ignoring it", r.id, StripPrefixKey)
+		return "", false
+	}
+	return pre, true
+}
+
+// RewritePath returns the regexp and replacement of the request path, given as
+// '<regexp> <replacement>', e.g. '^/v1/(.*) /api/$1'
+func (r *Resource) RewritePath() (expr, replacement string, ok bool) {
+	val, ok := r.GetAnnotation(RewritePathKey)
+	if !ok {
+		return "", "", false
+	}
+	bits := strings.Fields(val)
+	if len(bits) != 2 {
+		logger.Warnf("[%v] %s should be '<regexp> <replacement>', got %q", r.id, RewritePathKey, val)
+		return "", "", false
+	}
+	if _, er := regexp.Compile(bits[0]); er != nil {
+		logger.Warnf("[%v] Bad %s regexp: %v", r.id, RewritePathKey, er)
+		return "", "", false
+	}
+	return bits[0], bits[1], true
+}
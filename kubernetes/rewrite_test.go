@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripPrefix(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			annotations map[string]string
+			prefix      string
+			ok          bool
+		}{
+			{map[string]string{"romulus/prefix": "/blog"}, "", false},
+			{map[string]string{"romulus/prefix": "/blog", "romulus/strip_prefix": "true"}, "/blog", true},
+			{map[string]string{"romulus/prefix": "/blog", "romulus/strip_prefix": "false"}, "", false},
+			{map[string]string{"romulus/prefix": "|/b.og|", "romulus/strip_prefix": "true"}, "", false},
+			{map[string]string{"romulus/path": "/blog", "romulus/strip_prefix": "true"}, "", false},
+		}
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	for _, t := range tests {
+		pre, ok := NewResource("test.blog.web", "web", t.annotations).StripPrefix()
+		is.Equal(t.ok, ok, "%v", t.annotations)
+		is.Equal(t.prefix, pre, "%v", t.annotations)
+	}
+}
+
+func TestRewritePath(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			value             string
+			expr, replacement string
+			ok                bool
+		}{
+			{"^/v1/(.*) /api/$1", "^/v1/(.*)", "/api/$1", true},
+			{"  ^/old   /new ", "^/old", "/new", true},
+			{"^/v1/(.*)", "", "", false},
+			{"^/v1/(.* /api/$1", "", "", false},
+		}
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	for _, t := range tests {
+		r := NewResource("test.api.web", "web", map[string]string{"romulus/rewrite_path": t.value})
+		expr, repl, ok := r.RewritePath()
+		is.Equal(t.ok, ok, t.value)
+		is.Equal(t.expr, expr, t.value)
+		is.Equal(t.replacement, repl, t.value)
+	}
+}
@@ -0,0 +1,95 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"github.com/bradfitz/slice"
+)
+
+// FrontendID returns the ID of the frontend of the Resource, its backend always has the Resource ID
+func (r *Resource) FrontendID() string {
+	if r.frontend != "" {
+		return r.frontend
+	}
+	return r.id
+}
+
+// FrontendResourceID returns the ID of the Resource a frontend ID belongs to, see Frontends
+func FrontendResourceID(frontendID string) string {
+	i := strings.LastIndex(frontendID, ".")
+	if i < 0 {
+		return frontendID
+	}
+	if last := frontendID[i+1:]; last == "redirect" || strings.HasPrefix(last, RouteKey+"-") {
+		return frontendID[:i]
+	}
+	return frontendID
+}
+
+// Frontends returns a Resource per frontend of r, all sharing its backend. Besides its own route
+// these are the alternatives from route.N.* annotations (e.g. romulus/route.1.host), each with
+// the frontend ID <resource ID>.route-N so it does not change when other alternatives go away.
+// Last comes the redirect frontend, see redirectFrontend. The own route is left out when it is
+// empty and there are other frontends.
+func (r *Resource) Frontends() ResourceList {
+	var (
+		list     = make(ResourceList, 0, len(r.routes)+2)
+		redirect = r.redirectFrontend()
+	)
+	if !r.Route.Empty() || (len(r.routes) < 1 && redirect == nil) {
+		list = append(list, r)
+	}
+
+	keys := make([]string, 0, len(r.routes))
+	for n := range r.routes {
+		keys = append(keys, n)
+	}
+	slice.Sort(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	for _, n := range keys {
+		alt := *r
+		alt.Route = r.routes[n]
+		alt.frontend = fmt.Sprintf("%s.%s-%s", r.id, RouteKey, n)
+		alt.routes = nil
+		list = append(list, &alt)
+	}
+	if redirect != nil {
+		list = append(list, redirect)
+	}
+	return list
+}
+
+// alternativeRoutes builds the routes of route.N.<key> annotations, grouped by N
+func alternativeRoutes(id string, anno annotations) map[string]*Route {
+	var (
+		groups = make(map[string]annotations)
+		routes = make(map[string]*Route)
+	)
+
+	for key, val := range anno {
+		bits := strings.SplitN(key, ".", 3)
+		if len(bits) != 3 || bits[0] != RouteKey {
+			continue
+		}
+		if _, ok := groups[bits[1]]; !ok {
+			groups[bits[1]] = make(map[string]string)
+		}
+		groups[bits[1]][bits[2]] = val
+	}
+	for n, an := range groups {
+		rt := NewRoute(id, an)
+		if rt.Empty() {
+			logger.Warnf("[%v] Alternative route %s.%s matches nothing, ignoring it", id, RouteKey, n)
+			continue
+		}
+		routes[n] = rt
+	}
+	return routes
+}
+
+func isResourceWide(key string) bool {
+	return key == "middleware" || key == RouteKey
+}
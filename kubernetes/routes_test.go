@@ -0,0 +1,70 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlternativeRoutes(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			annotations map[string]string
+			frontends   map[string]string
+		}{
+			{
+				map[string]string{"romulus/host": "a.com"},
+				map[string]string{"test.app.web": "Route(host(`a.com`))"},
+			},
+			{
+				map[string]string{
+					"romulus/route.1.host":   "a.com",
+					"romulus/route.2.host":   "b.com",
+					"romulus/route.2.prefix": "/b",
+				},
+				map[string]string{
+					"test.app.web.route-1": "Route(host(`a.com`))",
+					"test.app.web.route-2": "Route(host(`b.com`) && prefix(`/b`))",
+				},
+			},
+			{
+				map[string]string{
+					"romulus/host":             "a.com",
+					"romulus/web.route.3.path": "/c",
+					"romulus/api.route.4.path": "/d",
+				},
+				map[string]string{
+					"test.app.web":         "Route(host(`a.com`))",
+					"test.app.web.route-3": "Route(path(`/c`))",
+				},
+			},
+		}
+	)
+
+	defer func(k string) { Keyspace = k }(Keyspace)
+	Keyspace = "romulus"
+	for _, t := range tests {
+		r := NewResource("test.app.web", "web", t.annotations)
+		frontends := make(map[string]string)
+		for _, fr := range r.Frontends() {
+			is.Equal("test.app.web", fr.ID(), "frontends share the backend")
+			frontends[fr.FrontendID()] = fr.Route.String()
+		}
+		is.Equal(t.frontends, frontends, "%v", t.annotations)
+	}
+}
+
+func TestFrontendResourceID(te *testing.T) {
+	is := assert.New(te)
+	r := NewResource("test.app.web", "web", map[string]string{
+		"route.1.host":  "a.example.com",
+		"redirect_host": "www.example.com",
+		"redirect_to":   "example.com",
+	})
+	for _, fr := range r.Frontends() {
+		is.Equal("test.app.web", FrontendResourceID(fr.FrontendID()))
+	}
+	is.Equal("test.app.80", FrontendResourceID("test.app.80"))
+	is.Equal("web", FrontendResourceID("web"))
+}
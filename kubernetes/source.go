@@ -0,0 +1,146 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/albertrdixon/gearbox/logger"
+	"golang.org/x/net/context"
+
+	"k8s.io/kubernetes/pkg/controller/framework"
+)
+
+// Source feeds Service, Endpoints, Ingress, Node, Namespace and ConfigMap objects into a Cache and an Updater
+type Source interface {
+	// Kind returns the name of the Source
+	Kind() string
+	// Status returns an error if the Source is not usable
+	Status() error
+	// Client returns the api client used for cache misses, may be nil
+	Client() SuperClient
+	// Run populates the Cache and starts sending events to the Updater until ctx is done
+	Run(u Updater, c *Cache, sel Selector, resync time.Duration, ctx context.Context) error
+}
+
+type apiSource struct {
+	client  *Client
+	ingress bool
+	pods    *framework.Controller
+}
+
+// NewAPISource returns a Source backed by the kubernetes api
+func NewAPISource(client *Client) Source {
+	return &apiSource{client: client}
+}
+
+func (a *apiSource) Kind() string        { return "kubernetes" }
+func (a *apiSource) Client() SuperClient { return a.client }
+
+func (a *apiSource) Status() error {
+	var er error
+	if er = Status(a.client); er != nil {
+		return fmt.Errorf("Failed to connect to kubernetes: %v", er)
+	}
+	if er = NegotiateVersion(a.client); er != nil {
+		return fmt.Errorf("Failed to negotiate kubernetes api version: %v", er)
+	}
+	logger.Infof("Using kubernetes api version=%s", a.client.GetVersion())
+
+	if a.ingress, er = IngressSupported(a.client); er != nil {
+		return fmt.Errorf("Failed to discover kubernetes api resources: %v", er)
+	}
+	if !a.ingress {
+		logger.Warnf("Kubernetes api does not serve %s Ingress, Ingress watcher disabled", ExtensionsGroupVersion)
+	}
+	return nil
+}
+
+func (a *apiSource) Run(u Updater, c *Cache, sel Selector, resync time.Duration, ctx context.Context) error {
+	c.selector = selectorFromMap(sel)
+	a.createObjectCache(u, c, sel, resync, ctx)
+	time.Sleep(200 * time.Millisecond)
+	return a.createCallbacks(u, sel, resync, ctx)
+}
+
+func (a *apiSource) createObjectCache(u Updater, c *Cache, sel Selector, resync time.Duration, ctx context.Context) {
+	var (
+		uc = a.client.GetUnversionedClient()
+		ec = a.client.GetExtensionsClient()
+	)
+
+	logger.Infof("Creating kubernetes object cache")
+
+	service, er := CreateStore(ServicesKind, uc, sel, resync, ctx)
+	if er != nil {
+		logger.Warnf("Failed to create Service cache")
+	}
+	endpoints, er := CreateStore(EndpointsKind, uc, sel, resync, ctx)
+	if er != nil {
+		logger.Warnf("Failed to create Endpoints cache")
+	}
+	if a.ingress {
+		ingress, er := CreateStore(IngressesKind, ec, sel, resync, ctx)
+		if er != nil {
+			logger.Warnf("Failed to create Ingress cache")
+		}
+		c.SetIngressStore(ingress)
+	}
+
+	// Pods are looked up for server weights, so they are not filtered by the selector. Their
+	// watcher starts with the others in createCallbacks.
+	pods, podWatch := CreatePodController(u, uc, resync)
+	a.pods = podWatch
+
+	// Nodes are cluster wide and back every NodePort Service
+	nodes, er := CreateStore(NodesKind, uc, nil, resync, ctx)
+	if er != nil {
+		logger.Warnf("Failed to create Node cache")
+	}
+
+	// Namespaces are looked up for default annotations and cross-namespace Ingress permissions
+	namespaces, er := CreateStore(NamespacesKind, uc, nil, resync, ctx)
+	if er != nil {
+		logger.Warnf("Failed to create Namespace cache")
+	}
+
+	c.SetServiceStore(service)
+	c.SetEndpointsStore(endpoints)
+	c.SetPodStore(pods)
+	c.SetNodeStore(nodes)
+	c.SetNamespaceStore(namespaces)
+	if ConfigMap != "" {
+		namespace, name, _ := ParseConfigMap(ConfigMap)
+		c.SetConfigStore(CreateConfigMapStore(ec, namespace, name, resync, ctx))
+	}
+}
+
+func (a *apiSource) createCallbacks(u Updater, sel Selector, resync time.Duration, ctx context.Context) error {
+	var (
+		uc = a.client.GetUnversionedClient()
+		ec = a.client.GetExtensionsClient()
+	)
+
+	logger.Infof("Starting kubernetes watchers")
+
+	_, endpoint := CreateFullController(EndpointsKind, u, uc, sel, resync)
+	_, service := CreateFullController(ServicesKind, u, uc, sel, resync)
+
+	_, node := CreateFullController(NodesKind, u, uc, nil, resync)
+	_, namespace := CreateFullController(NamespacesKind, u, uc, nil, resync)
+
+	go endpoint.Run(ctx.Done())
+	go service.Run(ctx.Done())
+	go node.Run(ctx.Done())
+	go namespace.Run(ctx.Done())
+	go a.pods.Run(ctx.Done())
+	if ConfigMap != "" {
+		ns, name, _ := ParseConfigMap(ConfigMap)
+		_, config := CreateConfigMapController(u, ec, ns, name, resync)
+		go config.Run(ctx.Done())
+	}
+	if a.ingress {
+		_, ingress := CreateFullController(IngressesKind, u, ec, sel, resync)
+		go ingress.Run(ctx.Done())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package kubernetes
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+// IsSplit returns true if the Resource is served by a weighted set of Services
+func (r *Resource) IsSplit() bool {
+	_, ok := r.GetAnnotation(SplitKey)
+	return ok
+}
+
+// GenDependentResources returns the Resources of the Services that split traffic onto the Service
+// behind obj or, for Nodes, the NodePort Resources and, for Namespaces, the Resources in the
+// Namespace and, for the ConfigMap, all Resources. They have to be regenerated whenever obj changes.
+func GenDependentResources(store *Cache, client SuperClient, obj interface{}) (ResourceList, error) {
+	var (
+		list ResourceList = make([]*Resource, 0, 1)
+
+		namespace, name string
+	)
+
+	switch t := obj.(type) {
+	default:
+		return list, nil
+	case *api.Service:
+		namespace, name = t.GetNamespace(), t.GetName()
+	case *api.Endpoints:
+		namespace, name = t.GetNamespace(), t.GetName()
+	case *api.Node:
+		list = nodePortResources(store, client)
+		Sort(list, ByID)
+		return list, nil
+	case *api.Namespace:
+		list = namespaceResources(store, client, t)
+		Sort(list, ByID)
+		return list, nil
+	case *extensions.ConfigMap:
+		list = configResources(store, client)
+		Sort(list, ByID)
+		return list, nil
+	}
+
+	for owner := range store.splitOwners(namespace, name) {
+		svc, er := store.GetService(client, namespace, owner)
+		if er != nil {
+			logger.Warnf("Unable to regenerate split Service %q: %v", owner, er)
+			continue
+		}
+		list = append(list, resourcesFromService(store, client, svc)...)
+	}
+	Sort(list, ByID)
+	return list, nil
+}
+
+// applyIngressAnnotations copies the split annotation for a backend Service from the Ingress
+// (romulus/split.<service>) to the Resource.
+func applyIngressAnnotations(r *Resource, in *extensions.Ingress, service string) {
+	key := path.Join(Keyspace, strings.Join([]string{SplitKey, service}, "."))
+	if val, ok := in.ObjectMeta.Annotations[key]; ok {
+		r.annotations[SplitKey] = val
+	}
+}
+
+func addServersFromSplit(store *Cache, client unversioned.Interface, r *Resource, namespace string, p api.ServicePort) {
+	var (
+		val, _ = r.GetAnnotation(SplitKey)
+		shares = parseSplit(r.id, val)
+		groups = make(map[string][]*Server, len(shares))
+		owner  = r.service
+	)
+
+	logger.Debugf("[%v] Adding Servers from split %v", r.id, shares)
+	for name := range shares {
+		store.mapSplit(namespace, name, owner)
+		svc, er := store.GetService(client, namespace, name)
+		if er != nil {
+			logger.Warnf("[%v] Split Service missing: %v", r.id, er)
+			continue
+		}
+		port, ok := splitPort(svc, p)
+		if !ok {
+			logger.Warnf("[%v] No port matching %v in %v", r.id, p, Service(*svc))
+			continue
+		}
+		en, er := store.GetEndpoints(client, namespace, name)
+		if er != nil {
+			logger.Warnf("[%v] No Endpoints for split %v", r.id, Service(*svc))
+			continue
+		}
+
+		member := &Resource{id: r.id, annotations: r.annotations}
+		if er := addServersFromEndpoints(member, en, port); er != nil {
+			logger.Warnf("[%v] Invalid split %v: %v", r.id, Endpoints(*en), er)
+		}
+		groups[name] = member.servers
+		r.servers = append(r.servers, member.servers...)
+	}
+	shareWeights(r.id, groups, shares)
+	reduceWeights(r.servers)
+}
+
+// splitPort finds the port of a split Service matching the port of the Service that declared the split
+func splitPort(svc *api.Service, p api.ServicePort) (api.ServicePort, bool) {
+	for _, port := range svc.Spec.Ports {
+		if p.Name != "" && port.Name == p.Name {
+			return port, true
+		}
+	}
+	for _, port := range svc.Spec.Ports {
+		if port.Port == p.Port {
+			return port, true
+		}
+	}
+	if len(svc.Spec.Ports) == 1 {
+		return svc.Spec.Ports[0], true
+	}
+	return api.ServicePort{}, false
+}
+
+// parseSplit parses a split annotation of the form "api-v1:90, api-v2:10"
+func parseSplit(id, val string) map[string]int {
+	m := make(map[string]int)
+	for _, bit := range strings.Split(val, ",") {
+		kv := strings.SplitN(strings.TrimSpace(bit), ":", 2)
+		if kv[0] == "" {
+			continue
+		}
+		if len(kv) < 2 {
+			m[kv[0]] = 1
+			continue
+		}
+		w, er := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if er != nil || w < 0 {
+			logger.Warnf("[%v] Invalid split weight %q for %q", id, kv[1], kv[0])
+			continue
+		}
+		m[kv[0]] = w
+	}
+	return m
+}
+
+func (k *Cache) mapSplit(namespace, member string, owner cache.ExplicitKey) {
+	key := cacheLookupKey(namespace, member)
+	if _, ok := k.splitMap[key]; !ok {
+		k.splitMap[key] = make(map[cache.ExplicitKey]bool)
+	}
+	logger.Debugf("Mapping Service(%q) -> split Service(%q)", key, owner)
+	k.splitMap[key][owner] = true
+}
+
+func (k *Cache) splitOwners(namespace, name string) map[string]bool {
+	owners := make(map[string]bool)
+	for owner := range k.splitMap[cacheLookupKey(namespace, name)] {
+		bits := strings.SplitN(string(owner), "/", 2)
+		owners[bits[len(bits)-1]] = true
+	}
+	return owners
+}
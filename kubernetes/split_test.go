@@ -0,0 +1,94 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func testService(name string, annotations map[string]string) *api.Service {
+	return &api.Service{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: "test", Annotations: annotations},
+		Spec: api.ServiceSpec{
+			Type:  api.ServiceTypeClusterIP,
+			Ports: []api.ServicePort{{Name: "web", Port: 80}},
+		},
+	}
+}
+
+func testEndpoints(name string, ips ...string) *api.Endpoints {
+	addrs := make([]api.EndpointAddress, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, api.EndpointAddress{IP: ip})
+	}
+	return &api.Endpoints{
+		ObjectMeta: api.ObjectMeta{Name: name, Namespace: "test"},
+		Subsets: []api.EndpointSubset{{
+			Addresses: addrs,
+			Ports:     []api.EndpointPort{{Name: "web", Port: 8080}},
+		}},
+	}
+}
+
+func TestSplit(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		svc  = testService("api", map[string]string{"romulus/split": "api-v1:90, api-v2:10"})
+	)
+
+	c.service.Add(svc)
+	c.service.Add(testService("api-v1", nil))
+	c.service.Add(testService("api-v2", nil))
+	c.endpoints.Add(testEndpoints("api-v1", "10.0.0.1", "10.0.0.2"))
+	c.endpoints.Add(testEndpoints("api-v2", "10.0.1.1"))
+
+	list, er := GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+
+	weights := map[string]int{}
+	for _, s := range list[0].Servers() {
+		weights[s.ip] = s.Weight()
+	}
+	is.Equal(map[string]int{"10.0.0.1": 9, "10.0.0.2": 9, "10.0.1.1": 2}, weights)
+
+	deps, er := GenDependentResources(c, nil, testEndpoints("api-v2", "10.0.1.1"))
+	if is.NoError(er) && is.Len(deps, 1) {
+		is.Equal(list[0].ID(), deps[0].ID())
+	}
+	deps, _ = GenDependentResources(c, nil, testEndpoints("other"))
+	is.Empty(deps)
+}
+
+func TestSplitSkipsNotReady(te *testing.T) {
+	var (
+		is   = assert.New(te)
+		must = require.New(te)
+		c    = NewCache()
+		svc  = testService("api", map[string]string{"romulus/split": "api-v1:90, api-v2:10"})
+		v1   = testEndpoints("api-v1", "10.0.0.1", "10.0.0.2")
+	)
+
+	v1.Subsets[0].NotReadyAddresses = []api.EndpointAddress{{IP: "10.0.0.3"}}
+	c.service.Add(svc)
+	c.service.Add(testService("api-v1", nil))
+	c.service.Add(testService("api-v2", nil))
+	c.endpoints.Add(v1)
+	c.endpoints.Add(testEndpoints("api-v2", "10.0.1.1"))
+
+	list, er := GenResources(c, nil, svc)
+	must.NoError(er)
+	must.Len(list, 1)
+
+	weights := map[string]int{}
+	for _, s := range list[0].Servers() {
+		if s.Weight() > 0 {
+			weights[s.ip] = s.Weight()
+		}
+	}
+	is.Equal(map[string]int{"10.0.0.1": 9, "10.0.0.2": 9, "10.0.1.1": 2}, weights, "NotReady pods get no share")
+}
@@ -5,13 +5,16 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bradfitz/slice"
 
 	"k8s.io/kubernetes/pkg/api"
+	kunversioned "k8s.io/kubernetes/pkg/api/unversioned"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/client/cache"
 	"k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
 )
 
 // Updater will be fed into framework.ResourceEventHandlerFuncs
@@ -29,29 +32,48 @@ type SuperClient interface {
 
 type Client struct {
 	*unversioned.Client
+	version kunversioned.GroupVersion
 }
 
 // Cache is a convenience struct so we can pass around all our various caches
 type Cache struct {
 	ingress, service, endpoints cache.Store
+	pod, node, namespace        cache.Store
+	config                      cache.Store
 	ingMap                      map[cache.ExplicitKey]cache.ExplicitKey
+	splitMap                    map[cache.ExplicitKey]map[cache.ExplicitKey]bool
+	podGroups                   map[string]map[string]bool
+	podTargets                  map[cache.ExplicitKey]map[cache.ExplicitKey]bool
+	selector                    labels.Selector
+	known                       map[string]map[string]*Server
+	drains                      map[string]time.Time
 }
 
 // Resource is a single loadbalancer Resource or service pulled out of kubernetes objects
 type Resource struct {
 	*Route
 	id          string
+	frontend    string
+	routes      map[string]*Route
+	redirect    *Redirect
+	service     cache.ExplicitKey
 	annotations annotations
 	servers     ServerList
 	websocket   bool
+	affinity    bool
+	podGroup    string
+	refreshIn   time.Duration
+	created     time.Time
 }
 
 type ResourceList []*Resource
 
 type Server struct {
 	id, scheme, ip string
-	port           int
+	port, weight   int
 	websocket      bool
+	draining       bool
+	target         *api.ObjectReference
 }
 
 type ServerList []*Server
@@ -131,9 +153,24 @@ func (s ServerList) String() string {
 
 func (r Route) String() string {
 	rt := []string{}
+	hosts := []string{}
 	for _, part := range r.parts {
+		if part.kind == HostPart {
+			hosts = append(hosts, part.String())
+			continue
+		}
 		rt = append(rt, part.String())
 	}
+	switch len(hosts) {
+	case 0:
+	case 1:
+		rt = append(rt, hosts[0])
+	default:
+		slice.Sort(hosts, func(i, j int) bool {
+			return hosts[i] < hosts[j]
+		})
+		rt = append(rt, fmt.Sprintf("(%s)", strings.Join(hosts, " || ")))
+	}
 	slice.Sort(rt, func(i, j int) bool {
 		return rt[i] < rt[j]
 	})
@@ -161,5 +198,12 @@ func (r routePart) String() string {
 }
 
 func (s Server) String() string {
-	return fmt.Sprintf(`Server(url="%v")`, s.URL())
+	var extra string
+	if s.target != nil && s.target.Name != "" {
+		extra = fmt.Sprintf(`, %s=%q`, strings.ToLower(s.target.Kind), s.target.Name)
+	}
+	if s.draining {
+		extra += ", draining"
+	}
+	return fmt.Sprintf(`Server(url="%v"%s)`, s.URL(), extra)
 }
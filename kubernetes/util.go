@@ -4,7 +4,9 @@ import (
 	"crypto/md5"
 	"fmt"
 	"path"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/albertrdixon/gearbox/logger"
 	"github.com/albertrdixon/gearbox/util"
@@ -40,11 +42,77 @@ func GenServerID(namespace, name, ip string, port int) string {
 	return strings.Join(id, ".")
 }
 
-func matchPort(a api.ServicePort, b api.EndpointPort) bool {
-	if a.Name != "" && b.Name != "" {
-		return a.Name == b.Name
+// GenServerIDFromRef names a server after the object behind it and its port (e.g.
+// "ns.svc.web-1-8080-0b6a6e4c" for port 8080 of Pod web-1), so server lists are readable, the ports
+// of one Pod or Node get different IDs and a reused IP gets a new ID with the new Pod UID.
+// Without a TargetRef it falls back to GenServerID.
+func GenServerIDFromRef(namespace, name string, ref *api.ObjectReference, ip string, port int) string {
+	if ref == nil || ref.Name == "" {
+		return GenServerID(namespace, name, ip, port)
 	}
-	return true
+	suffix := string(ref.UID)
+	if suffix == "" {
+		suffix = util.Hashf(md5.New(), ip, port, namespace, name)
+	}
+	if len(suffix) > hashLen {
+		suffix = suffix[:hashLen]
+	}
+	id := []string{namespace, name, strings.Join([]string{ref.Name, strconv.Itoa(port), suffix}, "-")}
+	return strings.Join(id, ".")
+}
+
+// endpointPorts returns the ports of an Endpoints subset that serve the Service port p. The
+// endpoints controller names Endpoints ports after their Service port and resolves the targetPort,
+// which may differ between Pods, into the port number. Unnamed ports are only valid on single-port
+// Services, so an unnamed Service port matches the one unnamed port of the subset.
+func endpointPorts(p api.ServicePort, sub api.EndpointSubset) ([]api.EndpointPort, error) {
+	var (
+		ports   = make([]api.EndpointPort, 0, 1)
+		unnamed = make([]api.EndpointPort, 0, 1)
+		target  = targetPortNumber(p)
+	)
+
+	for _, port := range sub.Ports {
+		switch {
+		case p.Name != "" && port.Name == p.Name:
+			ports = append(ports, port)
+		case port.Name == "":
+			unnamed = append(unnamed, port)
+		}
+	}
+	if len(ports) > 0 || len(unnamed) == 0 {
+		return ports, nil
+	}
+
+	if p.Name != "" && len(sub.Ports) > 1 {
+		return ports, fmt.Errorf("unnamed Endpoints port in multi-port subset can not serve Service port %q", p.Name)
+	}
+	if len(unnamed) == 1 {
+		if target != 0 && unnamed[0].Port != target {
+			return ports, fmt.Errorf("Endpoints port %d does not match target port %d of Service port %d", unnamed[0].Port, target, p.Port)
+		}
+		return unnamed, nil
+	}
+	for _, port := range unnamed {
+		if target != 0 && port.Port == target {
+			ports = append(ports, port)
+		}
+	}
+	if len(ports) < 1 {
+		return ports, fmt.Errorf("%d unnamed Endpoints ports, none matching target port %v of Service port %d", len(unnamed), p.TargetPort.String(), p.Port)
+	}
+	return ports, nil
+}
+
+// targetPortNumber returns the numeric target port of p, or 0 if it is a named port
+func targetPortNumber(p api.ServicePort) int {
+	switch {
+	case p.TargetPort.Type == intstr.String:
+		return 0
+	case p.TargetPort.IntValue() != 0:
+		return p.TargetPort.IntValue()
+	}
+	return p.Port
 }
 
 func matchIntStr(str string, num int, is intstr.IntOrString) bool {
@@ -58,13 +126,41 @@ func matchIntStr(str string, num int, is intstr.IntOrString) bool {
 	}
 }
 
-func matchIngressBackend(serviceName string, servicePort api.ServicePort, backend extensions.IngressBackend) bool {
-	logger.Debugf("Comparing Service(Name=%q, Port=%v) with IngressBackend(%v)", serviceName, servicePort, backend)
-	nameMatch := serviceName == backend.ServiceName
+func matchIngressBackend(in *extensions.Ingress, namespace, serviceName string, servicePort api.ServicePort, backend extensions.IngressBackend) bool {
+	logger.Debugf("Comparing Service(Name=%q, Namespace=%q, Port=%v) with IngressBackend(%v)", serviceName, namespace, servicePort, backend)
+	ns, name := ingressBackendService(in, backend)
+	nameMatch := ns == namespace && name == serviceName
 	isMatch := matchIntStr(servicePort.Name, servicePort.Port, backend.ServicePort)
 	logger.Debugf("NameMatch = %v intstrMatch = %v", nameMatch, isMatch)
-	return serviceName == backend.ServiceName &&
-		matchIntStr(servicePort.Name, servicePort.Port, backend.ServicePort)
+	return nameMatch && isMatch
+}
+
+// namedValues parses 'name=value; other=|regexp|' lists of header, query and cookie matchers
+func namedValues(val string) [][]string {
+	list := make([][]string, 0, 1)
+	for _, v := range strings.Fields(strings.Replace(val, ";", "", -1)) {
+		bits := strings.SplitN(v, "=", 2)
+		if len(bits) < 2 {
+			continue
+		}
+		list = append(list, bits)
+	}
+	return list
+}
+
+// isWildcardHost is true for hosts like "*.example.com", a single wildcard label in front of a domain
+func isWildcardHost(host string) bool {
+	return len(host) > 2 && strings.HasPrefix(host, "*.") && !strings.Contains(host[2:], "*")
+}
+
+// normalHost lowercases a host and strips a trailing dot, hosts are matched regardless of both
+func normalHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// isListSeparator splits annotation lists like "a.example.com, b.example.com"
+func isListSeparator(r rune) bool {
+	return r == ',' || r == ';' || unicode.IsSpace(r)
 }
 
 func cacheLookupKey(namespace, name string) cache.ExplicitKey {
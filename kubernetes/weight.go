@@ -0,0 +1,263 @@
+package kubernetes
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/albertrdixon/gearbox/logger"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/cache"
+	"k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const (
+	anyGroup = "*"
+
+	// MaxWeight is the largest server weight split Services are reduced to
+	MaxWeight = 100
+	// WeightTolerance is how far, as a fraction of the traffic, reduced weights may be off
+	WeightTolerance = 0.005
+)
+
+// UsesPodWeights returns true if servers of the Resource get their weight from their Pods
+func (r *Resource) UsesPodWeights() bool {
+	if _, ok := r.GetAnnotation(WeightsKey); ok {
+		return true
+	}
+	val, _ := r.GetAnnotation(PodWeightsKey)
+	b, _ := strconv.ParseBool(val)
+	return b
+}
+
+// weighServers follows each server's TargetRef to its Pod and sets the server weight from the Pod
+// weight annotation or, failing that, from the share of its group in the Resource weights annotation.
+// Group shares are split evenly between the Pods of the group.
+func weighServers(store *Cache, client unversioned.Interface, r *Resource) {
+	if !r.UsesPodWeights() {
+		return
+	}
+
+	var (
+		label, _ = r.GetAnnotation(WeightLabelKey)
+		shares   = parseWeights(r)
+		groups   = make(map[string][]*Server)
+		key      = path.Join(Keyspace, WeightKey)
+	)
+
+	for _, s := range r.servers {
+		if s.draining || s.target == nil || s.target.Kind != "Pod" {
+			continue
+		}
+		pod, er := store.GetPod(client, s.target.Namespace, s.target.Name)
+		if er != nil {
+			logger.Warnf("[%v] Unable to weigh %v: %v", r.id, s, er)
+			continue
+		}
+		store.mapPodTarget(pod, r.service)
+		if val, ok := pod.ObjectMeta.Annotations[key]; ok {
+			if w, er := strconv.Atoi(val); er == nil && w >= 0 {
+				s.weight = w
+				continue
+			}
+			logger.Warnf("[%v] Invalid weight %q on Pod(%q)", r.id, val, pod.GetName())
+		}
+		if label != "" && shares != nil {
+			group := pod.ObjectMeta.Labels[label]
+			groups[group] = append(groups[group], s)
+		}
+	}
+	if len(groups) > 0 {
+		shareWeights(r.id, groups, shares)
+		reduceWeights(r.servers)
+	}
+}
+
+// PodServices returns the Services with servers weighed by the Pod, which need rendering again
+// when its weight or labels change
+func (k *Cache) PodServices(pod *api.Pod) []cache.ExplicitKey {
+	list := make([]cache.ExplicitKey, 0, 1)
+	for svc := range k.podTargets[cacheLookupKey(pod.GetNamespace(), pod.GetName())] {
+		list = append(list, svc)
+	}
+	return list
+}
+
+func (k *Cache) mapPodTarget(pod *api.Pod, svc cache.ExplicitKey) {
+	key := cacheLookupKey(pod.GetNamespace(), pod.GetName())
+	if _, ok := k.podTargets[key]; !ok {
+		k.podTargets[key] = make(map[cache.ExplicitKey]bool)
+	}
+	k.podTargets[key][svc] = true
+}
+
+// PodWeightChanged returns true if old and next are the same Pod with a different weight
+// annotation or labels, which may put it in another weight group
+func PodWeightChanged(old, next interface{}) bool {
+	a, ok := old.(*api.Pod)
+	if !ok {
+		return false
+	}
+	b, ok := next.(*api.Pod)
+	if !ok {
+		return false
+	}
+	key := path.Join(Keyspace, WeightKey)
+	return a.ObjectMeta.Annotations[key] != b.ObjectMeta.Annotations[key] ||
+		!reflect.DeepEqual(a.ObjectMeta.Labels, b.ObjectMeta.Labels)
+}
+
+// shareWeights splits the share of each group of servers evenly between its servers. Draining
+// servers get no share and keep zero weight. Weights are reduced by FitWeights, so they stay
+// small when group sizes have no common multiple below MaxWeight.
+func shareWeights(id string, groups map[string][]*Server, shares map[string]int) {
+	var (
+		names   = make([]string, 0, len(groups))
+		weights = make([]float64, 0, len(groups))
+		counts  = make([]int, 0, len(groups))
+	)
+	for group, servers := range groups {
+		ready := make([]*Server, 0, len(servers))
+		for _, s := range servers {
+			if s.draining {
+				s.weight = 0
+				continue
+			}
+			ready = append(ready, s)
+		}
+		groups[group] = ready
+
+		share, ok := shares[group]
+		if !ok {
+			share, ok = shares[anyGroup]
+		}
+		if !ok {
+			logger.Warnf("[%v] No weight for %q, %d server(s) get no traffic", id, group, len(ready))
+		}
+		if len(ready) > 0 {
+			names = append(names, group)
+			weights = append(weights, float64(share)/float64(len(ready)))
+			counts = append(counts, len(ready))
+		}
+	}
+
+	fitted, drift := FitWeights(weights, counts, MaxWeight)
+	if drift > WeightTolerance {
+		logger.Warnf("[%v] Weights %v can not be split between %v servers with weights up to %d, shares are off by up to %.1f%% of the traffic",
+			id, shares, counts, MaxWeight, drift*100)
+	}
+	for i, group := range names {
+		for _, s := range groups[group] {
+			s.weight = fitted[i]
+		}
+	}
+}
+
+// FitWeights returns the smallest integer weights up to max giving every entry its share of the
+// traffic within WeightTolerance, where weights[i] is the weight of each of counts[i] servers.
+// Positive weights stay at least 1. If no weights up to max are close enough it returns those
+// up to max, along with the largest difference between the share of an entry and the one asked for.
+func FitWeights(weights []float64, counts []int, max int) ([]int, float64) {
+	var (
+		top    = 0.0
+		fitted = make([]int, len(weights))
+		drift  = 0.0
+	)
+	for _, w := range weights {
+		if w > top {
+			top = w
+		}
+	}
+	if top <= 0 {
+		return fitted, 0
+	}
+	for m := 1; m <= max; m++ {
+		for i, w := range weights {
+			fitted[i] = int(w/top*float64(m) + 0.5)
+			if fitted[i] < 1 && w > 0 {
+				fitted[i] = 1
+			}
+		}
+		if drift = weightDrift(weights, fitted, counts); drift <= WeightTolerance {
+			break
+		}
+	}
+	return fitted, drift
+}
+
+// weightDrift returns the largest difference between the traffic shares of weights and fitted
+func weightDrift(weights []float64, fitted, counts []int) float64 {
+	var (
+		total, sum = 0.0, 0
+		drift      = 0.0
+	)
+	for i := range weights {
+		total += weights[i] * float64(counts[i])
+		sum += fitted[i] * counts[i]
+	}
+	for i := range weights {
+		d := weights[i]*float64(counts[i])/total - float64(fitted[i]*counts[i])/float64(sum)
+		if d < 0 {
+			d = -d
+		}
+		if d > drift {
+			drift = d
+		}
+	}
+	return drift
+}
+
+// parseWeights parses a weights annotation of the form "stable=95, canary=5"
+func parseWeights(r *Resource) map[string]int {
+	val, ok := r.GetAnnotation(WeightsKey)
+	if !ok {
+		return nil
+	}
+
+	m := make(map[string]int)
+	for _, bit := range strings.Split(val, ",") {
+		kv := strings.SplitN(strings.TrimSpace(bit), "=", 2)
+		if len(kv) < 2 {
+			continue
+		}
+		w, er := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if er != nil || w < 0 {
+			logger.Warnf("[%v] Invalid weight %q for %q", r.id, kv[1], kv[0])
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = w
+	}
+	return m
+}
+
+func reduceWeights(servers []*Server) {
+	d := 0
+	for _, s := range servers {
+		if s.weight > 0 {
+			d = gcd(d, s.weight)
+		}
+	}
+	if d < 2 {
+		return
+	}
+	for _, s := range servers {
+		s.weight /= d
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func targetRef(addr api.EndpointAddress) *api.ObjectReference {
+	if addr.TargetRef == nil {
+		return nil
+	}
+	ref := *addr.TargetRef
+	return &ref
+}
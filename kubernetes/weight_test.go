@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/cache"
+)
+
+func testPod(name string, labels, annotations map[string]string) *api.Pod {
+	return &api.Pod{ObjectMeta: api.ObjectMeta{
+		Name:        name,
+		Namespace:   "test",
+		Labels:      labels,
+		Annotations: annotations,
+	}}
+}
+
+func TestWeighServers(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			annotations map[string]string
+			expected    []int
+		}{
+			{map[string]string{}, []int{1, 1, 1, 1, 1}},
+			{map[string]string{"romulus/pod_weights": "true"}, []int{1, 1, 1, 1, 7}},
+			{map[string]string{"romulus/weight_label": "track", "romulus/weights": "stable=90, canary=10"}, []int{3, 3, 3, 1, 7}},
+			{map[string]string{"romulus/weight_label": "track", "romulus/weights": "stable=9, canary=1"}, []int{3, 3, 3, 1, 7}},
+			{map[string]string{"romulus/weight_label": "track", "romulus/weights": "canary=10"}, []int{0, 0, 0, 1, 7}},
+			{map[string]string{"romulus/weight_label": "track", "romulus/weights": "canary=10, *=90"}, []int{3, 3, 3, 1, 7}},
+		}
+	)
+
+	c := NewCache()
+	c.pod.Add(testPod("stable-1", map[string]string{"track": "stable"}, nil))
+	c.pod.Add(testPod("stable-2", map[string]string{"track": "stable"}, nil))
+	c.pod.Add(testPod("stable-3", map[string]string{"track": "stable"}, nil))
+	c.pod.Add(testPod("canary-1", map[string]string{"track": "canary"}, nil))
+	c.pod.Add(testPod("pinned-1", map[string]string{"track": "canary"}, map[string]string{path.Join(Keyspace, WeightKey): "7"}))
+
+	for _, t := range tests {
+		r := NewResource("test.foo.80", "", t.annotations)
+		for i, name := range []string{"stable-1", "stable-2", "stable-3", "canary-1", "pinned-1"} {
+			ref := &api.ObjectReference{Kind: "Pod", Namespace: "test", Name: name}
+			r.addServer(&Server{id: name, scheme: HTTP, ip: fmt.Sprintf("10.0.0.%d", i), port: 80, weight: 1, target: ref})
+		}
+		weighServers(c, nil, r)
+
+		weights := make([]int, 0, len(t.expected))
+		for _, s := range r.Servers() {
+			weights = append(weights, s.Weight())
+		}
+		is.Equal(t.expected, weights, "annotations: %v", t.annotations)
+	}
+}
+
+func TestShareWeightsCoprimeGroups(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			shares  map[string]int
+			drifted bool
+		}{
+			{map[string]int{"stable": 90, "canary": 10}, false},
+			{map[string]int{"stable": 50, "canary": 50}, false},
+			{map[string]int{"stable": 99, "canary": 1}, true},
+		}
+	)
+
+	for _, t := range tests {
+		groups := map[string][]*Server{}
+		for group, n := range map[string]int{"stable": 7, "canary": 11} {
+			for i := 0; i < n; i++ {
+				groups[group] = append(groups[group], &Server{id: fmt.Sprintf("%s-%d", group, i), weight: 1})
+			}
+		}
+		shareWeights("test.foo.80", groups, t.shares)
+
+		total, sums := 0, map[string]int{}
+		for group, servers := range groups {
+			for _, s := range servers {
+				is.True(s.weight >= 1 && s.weight <= MaxWeight, "%v: weight %d", t.shares, s.weight)
+				sums[group] += s.weight
+				total += s.weight
+			}
+		}
+		drift := float64(sums["canary"])/float64(total) - float64(t.shares["canary"])/100
+		if drift < 0 {
+			drift = -drift
+		}
+		is.Equal(t.drifted, drift > WeightTolerance, "%v: canary gets %d of %d", t.shares, sums["canary"], total)
+		is.True(drift < 0.01, "%v: canary gets %d of %d", t.shares, sums["canary"], total)
+	}
+}
+
+func TestFitWeights(te *testing.T) {
+	is := assert.New(te)
+
+	fitted, drift := FitWeights([]float64{90.0 / 7, 10.0 / 11}, []int{7, 11}, MaxWeight)
+	is.True(drift <= WeightTolerance)
+	is.True(fitted[0] <= MaxWeight && fitted[1] >= 1, "%v", fitted)
+
+	fitted, drift = FitWeights([]float64{3, 2}, []int{1, 1}, MaxWeight)
+	is.Equal([]int{3, 2}, fitted)
+	is.Equal(0.0, drift)
+
+	fitted, _ = FitWeights([]float64{0, 0}, []int{1, 1}, MaxWeight)
+	is.Equal([]int{0, 0}, fitted)
+}
+
+func TestPodWeightChanges(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		c   = NewCache()
+		key = path.Join(Keyspace, WeightKey)
+		pod = testPod("stable-1", map[string]string{"track": "stable"}, nil)
+		r   = NewResource("test.foo.80", "", map[string]string{"romulus/pod_weights": "true"})
+	)
+
+	c.pod.Add(pod)
+	r.service = cacheLookupKey("test", "foo")
+	r.addServer(&Server{id: "stable-1", scheme: HTTP, ip: "10.0.0.1", port: 80, weight: 1, target: &api.ObjectReference{Kind: "Pod", Namespace: "test", Name: "stable-1"}})
+	weighServers(c, nil, r)
+	is.Equal([]cache.ExplicitKey{"test/foo"}, c.PodServices(pod))
+
+	next := testPod("stable-1", map[string]string{"track": "stable"}, map[string]string{key: "5"})
+	is.True(PodWeightChanged(pod, next))
+	is.True(PodWeightChanged(pod, testPod("stable-1", map[string]string{"track": "canary"}, nil)))
+	is.False(PodWeightChanged(pod, testPod("stable-1", map[string]string{"track": "stable"}, nil)))
+
+	c.PodDeleted(pod)
+	is.Empty(c.PodServices(pod))
+}
@@ -2,6 +2,8 @@ package loadbalancer
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/timelinelabs/romulus/kubernetes"
 )
@@ -11,6 +13,19 @@ var (
 	ErrUnexpectedBackendType  = errors.New("Backend is of unexpected type")
 )
 
+// UnsupportedRouteError is returned by NewFrontend for a route with matchers the loadbalancer
+// can not match on. Leaving them out would widen the route, so the frontend is not rendered.
+type UnsupportedRouteError struct {
+	Frontend string
+	Route    string
+	Reason   string
+	Parts    []string
+}
+
+func (e *UnsupportedRouteError) Error() string {
+	return fmt.Sprintf("%s %s not rendered, %s: %s", e.Frontend, e.Route, e.Reason, strings.Join(e.Parts, ", "))
+}
+
 const (
 	PassHostHeaderKey      = "pass_host_header"
 	TrustForwardHeadersKey = "trust_forward_headers"
@@ -30,6 +45,7 @@ const (
 type LoadBalancer interface {
 	NewFrontend(*kubernetes.Resource) (Frontend, error)
 	GetFrontend(string) (Frontend, error)
+	ListFrontends() ([]string, error)
 	UpsertFrontend(Frontend) error
 	DeleteFrontend(Frontend) error
 	NewBackend(*kubernetes.Resource) (Backend, error)
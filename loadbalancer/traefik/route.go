@@ -13,18 +13,39 @@ func NewRoute(rt *kubernetes.Route) map[string]types.Route {
 		r          = make(map[string]types.Route)
 		headers    = make([]string, 0, 1)
 		headersRgx = make([]string, 0, 1)
+		hosts      = make([]string, 0, 1)
+		queries    = make([]string, 0, 1)
+		wildcard   = false
+		cookie     = false
 	)
 
 	for _, part := range rt.Parts() {
 		switch part.Type() {
 		case kubernetes.HostPart:
-			r["host"] = types.Route{Rule: fmt.Sprintf("Host: %s", part.Value())}
+			if part.IsWildcard() {
+				hosts = append(hosts, "{subdomain:[^.]+}"+strings.TrimPrefix(part.Value(), "*"))
+				wildcard = true
+			} else {
+				hosts = append(hosts, part.Value())
+			}
 		case kubernetes.MethodPart:
 			r["methods"] = types.Route{Rule: fmt.Sprintf("Methods: %s", part.Value())}
 		case kubernetes.PathPart:
 			r["path"] = types.Route{Rule: fmt.Sprintf("Path: %s", part.Value())}
 		case kubernetes.PrefixPart:
 			r["prefix"] = types.Route{Rule: fmt.Sprintf("PathPrefix: %s", part.Value())}
+		case kubernetes.QueryPart:
+			if part.IsRegex() {
+				queries = append(queries, fmt.Sprintf("%s={%s:%s}", part.Header(), part.Header(), part.Value()))
+			} else {
+				queries = append(queries, fmt.Sprintf("%s=%s", part.Header(), part.Value()))
+			}
+		case kubernetes.CookiePart:
+			// Matched on the Cookie header, traefik keeps one regexp per header
+			if !cookie {
+				headersRgx = append(headersRgx, fmt.Sprintf("%q, %q", "Cookie", part.CookieRegexp()))
+				cookie = true
+			}
 		case kubernetes.HeaderPart:
 			head := fmt.Sprintf("%q, %q", part.Header(), part.Value())
 			if part.IsRegex() {
@@ -35,6 +56,15 @@ func NewRoute(rt *kubernetes.Route) map[string]types.Route {
 		}
 	}
 
+	switch {
+	case wildcard:
+		r["hostRegexp"] = types.Route{Rule: fmt.Sprintf("HostRegexp: %s", strings.Join(hosts, ", "))}
+	case len(hosts) > 0:
+		r["host"] = types.Route{Rule: fmt.Sprintf("Host: %s", strings.Join(hosts, ", "))}
+	}
+	if len(queries) > 0 {
+		r["query"] = types.Route{Rule: fmt.Sprintf("Query: %s", strings.Join(queries, ", "))}
+	}
 	if len(headers) > 0 {
 		r["headers"] = types.Route{Rule: fmt.Sprintf("Headers: %s", strings.Join(headers, ", "))}
 	}
@@ -44,3 +74,19 @@ func NewRoute(rt *kubernetes.Route) map[string]types.Route {
 
 	return r
 }
+
+// unsupportedParts returns the route parts traefik can not match on
+func unsupportedParts(rt *kubernetes.Route) []string {
+	var (
+		list    = make([]string, 0, 1)
+		cookies = 0
+	)
+	for _, part := range rt.Parts() {
+		if part.Type() == kubernetes.CookiePart {
+			if cookies++; cookies > 1 {
+				list = append(list, part.String())
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,158 @@
+package traefik
+
+import (
+	"testing"
+
+	"github.com/emilevauge/traefik/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/timelinelabs/romulus/kubernetes"
+	"github.com/timelinelabs/romulus/loadbalancer"
+)
+
+func TestBuildRoute(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			expected    map[string]types.Route
+			annotations map[string]string
+		}{
+			{map[string]types.Route{}, map[string]string{}},
+			{
+				map[string]types.Route{"host": {Rule: "Host: abc"}, "prefix": {Rule: "PathPrefix: /f"}},
+				map[string]string{"host": "abc", "prefix": "/f"},
+			},
+			{
+				map[string]types.Route{"host": {Rule: "Host: a.com, b.com"}},
+				map[string]string{"hosts": "a.com, b.com"},
+			},
+			{
+				map[string]types.Route{"hostRegexp": {Rule: "HostRegexp: {subdomain:[^.]+}.example.com, example.com"}},
+				map[string]string{"hosts": "*.example.com, example.com"},
+			},
+			{
+				map[string]types.Route{
+					"query":         {Rule: "Query: v={v:[12]}"},
+					"headersRegexp": {Rule: `HeadersRegexp: "Cookie", "(^|;\\s*)canary=yes(;|$)"`},
+				},
+				map[string]string{"query": "v=|[12]|", "cookies": "canary=yes"},
+			},
+		}
+	)
+
+	for _, t := range tests {
+		rt := kubernetes.NewRoute("foo", t.annotations)
+		is.Equal(t.expected, NewRoute(rt))
+	}
+}
+
+func TestUnsupportedRoute(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		rsc = kubernetes.NewResource("test.app.web", "web", map[string]string{"romulus/cookies": "canary=yes; beta=on"})
+	)
+
+	_, er := new(traefik).NewFrontend(rsc)
+	if is.IsType(&loadbalancer.UnsupportedRouteError{}, er, "a route without its second cookie matcher would be wider") {
+		is.Len(er.(*loadbalancer.UnsupportedRouteError).Parts, 1)
+	}
+}
+
+func TestRewriteRoutes(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		t   = new(traefik)
+		rsc = kubernetes.NewResource("test.blog.web", "web", map[string]string{
+			"romulus/prefix":       "/blog",
+			"romulus/strip_prefix": "true",
+			"romulus/rewrite_path": "^/old/(.*) /new/$1",
+		})
+	)
+
+	fr, er := t.NewFrontend(rsc)
+	if is.NoError(er) {
+		routes := fr.(*frontend).Routes
+		is.Equal("PathPrefixStrip: /blog", routes["prefix"].Rule)
+		is.Equal("ReplacePathRegex: ^/old/(.*) /new/$1", routes["rewrite"].Rule)
+	}
+}
+
+func TestFrontendPriority(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		t     = new(traefik)
+		tests = []struct {
+			priority    int
+			annotations map[string]string
+		}{
+			{1000, map[string]string{}},
+			{2004, map[string]string{"romulus/prefix": "/api"}},
+			{2007, map[string]string{"romulus/prefix": "/api/v2"}},
+			{3004, map[string]string{"romulus/path": "/api"}},
+			{5004, map[string]string{"romulus/host": "*.example.com", "romulus/prefix": "/api"}},
+			{7000, map[string]string{"romulus/host": "www.example.com"}},
+			{8004, map[string]string{"romulus/host": "www.example.com", "romulus/prefix": "/api"}},
+			{9004, map[string]string{"romulus/host": "www.example.com", "romulus/path": "/api"}},
+			{50, map[string]string{"romulus/host": "www.example.com", "romulus/priority": "50"}},
+		}
+	)
+
+	for _, test := range tests {
+		fr, er := t.NewFrontend(kubernetes.NewResource("test.app.web", "web", test.annotations))
+		if is.NoError(er) {
+			is.Equal(test.priority, fr.(*frontend).Priority, "%v", test.annotations)
+		}
+	}
+}
+
+func TestAlternativeFrontends(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		t   = new(traefik)
+		rsc = kubernetes.NewResource("test.app.web", "web", map[string]string{
+			"romulus/route.1.host": "a.com",
+		})
+	)
+
+	frs := rsc.Frontends()
+	if is.Len(frs, 1) {
+		fr, er := t.NewFrontend(frs[0])
+		if is.NoError(er) {
+			is.Equal("test.app.web.route-1", fr.GetID())
+			is.Equal("test.app.web", fr.(*frontend).Backend)
+		}
+	}
+}
+
+func TestRedirectFrontend(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		t   = new(traefik)
+		rsc = kubernetes.NewResource("test.app.web", "web", map[string]string{
+			"romulus/host":          "example.com",
+			"romulus/prefix":        "/blog",
+			"romulus/strip_prefix":  "true",
+			"romulus/redirect_host": "www.example.com",
+			"romulus/redirect_to":   "https://example.com",
+		})
+	)
+
+	frs := rsc.Frontends()
+	if !is.Len(frs, 2) {
+		return
+	}
+	fr, er := t.NewFrontend(frs[0])
+	if is.NoError(er) {
+		is.Nil(fr.(*frontend).Redirect)
+	}
+	fr, er = t.NewFrontend(frs[1])
+	if is.NoError(er) {
+		f := fr.(*frontend)
+		is.Equal("test.app.web.redirect", f.GetID())
+		is.Equal(map[string]types.Route{"host": {Rule: "Host: www.example.com"}}, f.Routes)
+		if is.NotNil(f.Redirect) {
+			is.Equal(kubernetes.RedirectExpr, f.Redirect.Regex)
+			is.Equal("https://example.com${2}", f.Redirect.Replacement)
+			is.True(f.Redirect.Permanent)
+		}
+	}
+}
@@ -28,9 +28,11 @@ const (
 	DefaultPrefix          = "/traefik"
 	LoadbalancingMethodKey = "loadbalancer_method"
 
-	cb  = "circuitbreaker"
-	lb  = "loadbalancer"
-	phh = "passHostHeader"
+	cb     = "circuitbreaker"
+	lb     = "loadbalancer"
+	phh    = "passHostHeader"
+	sticky = "sticky"
+	cookie = "stickiness/cookieName"
 
 	wrr, drr = "wrr", "drr"
 )
@@ -59,21 +61,66 @@ func (t *traefik) Status() error {
 }
 
 func (t *traefik) NewFrontend(rsc *kubernetes.Resource) (loadbalancer.Frontend, error) {
+	if parts := unsupportedParts(rsc.Route); len(parts) > 0 {
+		return nil, &loadbalancer.UnsupportedRouteError{
+			Frontend: rsc.FrontendID(),
+			Route:    rsc.Route.String(),
+			Reason:   "traefik matches one cookie per route",
+			Parts:    parts,
+		}
+	}
 	f := types.Frontend{Backend: rsc.ID(), PassHostHeader: false}
 	f.Routes = NewRoute(rsc.Route)
+	if resp, ok := rsc.NoEndpointsResponse(); ok {
+		logger.Warnf("[%v] traefik has no static responses, not answering with %v", rsc.FrontendID(), resp)
+	}
+	rd, redirects := rsc.Redirect()
+	if pre, ok := rsc.StripPrefix(); ok && !redirects {
+		f.Routes["prefix"] = types.Route{Rule: fmt.Sprintf("PathPrefixStrip: %s", pre)}
+	}
+	if expr, repl, ok := rsc.RewritePath(); ok && !redirects {
+		f.Routes["rewrite"] = types.Route{Rule: fmt.Sprintf("ReplacePathRegex: %s %s", expr, repl)}
+	}
 	if phh, ok := rsc.GetAnnotation(loadbalancer.PassHostHeaderKey); ok {
 		if val, er := strconv.ParseBool(phh); er == nil {
 			f.PassHostHeader = val
 		}
 	}
 
-	return &frontend{Frontend: f, id: rsc.ID(), middlewares: make([]*middleware, 0, 1)}, nil
+	fr := &frontend{
+		Frontend:    f,
+		id:          rsc.FrontendID(),
+		middlewares: make([]*middleware, 0, 1),
+		Priority:    rsc.Priority(),
+	}
+	if redirects {
+		fr.Redirect = &redirect{
+			Regex:       kubernetes.RedirectExpr,
+			Replacement: rd.Replacement(),
+			Permanent:   rd.Permanent(),
+		}
+	}
+	return fr, nil
 }
 
 func (t *traefik) GetFrontend(id string) (loadbalancer.Frontend, error) {
 	return getFrontend(t.Client, t.prefix, id)
 }
 
+func (t *traefik) ListFrontends() ([]string, error) {
+	keys, er := t.Keys(path.Join(t.prefix, "frontends"))
+	if er != nil {
+		return nil, er
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if id := path.Base(key); id != "." && id != "/" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
 func (t *traefik) UpsertFrontend(fr loadbalancer.Frontend) error {
 	f, ok := fr.(*frontend)
 	if !ok {
@@ -90,6 +137,30 @@ func (t *traefik) UpsertFrontend(fr loadbalancer.Frontend) error {
 		}
 	}
 
+	if f.Priority > 0 {
+		if er := t.Set(path.Join(pre, "priority"), strconv.Itoa(f.Priority)); er != nil {
+			logger.Warnf("[%v] Upsert priority error: %v", fr.GetID(), er)
+		}
+	} else {
+		t.clear(fr.GetID(), path.Join(pre, "priority"))
+	}
+
+	if f.Redirect == nil {
+		t.clear(fr.GetID(), path.Join(pre, "redirect"))
+	} else {
+		for key, val := range map[string]string{
+			"regex":       f.Redirect.Regex,
+			"replacement": f.Redirect.Replacement,
+			"permanent":   strconv.FormatBool(f.Redirect.Permanent),
+		} {
+			if er := t.Set(path.Join(pre, "redirect", key), val); er != nil {
+				logger.Warnf("[%v] Upsert redirect %s error: %v", fr.GetID(), key, er)
+			}
+		}
+	}
+
+	// routes of a dropped strip_prefix or rewrite_path must not stay behind
+	t.clear(fr.GetID(), path.Join(pre, "routes"))
 	for id, rt := range f.Routes {
 		logger.Debugf("[%v] Adding Route(%s=%q)", fr.GetID(), rt.Rule, rt.Value)
 		ruleK := path.Join(pre, "routes", id, "rule")
@@ -100,6 +171,13 @@ func (t *traefik) UpsertFrontend(fr loadbalancer.Frontend) error {
 	return nil
 }
 
+// clear deletes a key left from an earlier upsert, it may well not exist
+func (t *traefik) clear(id, key string) {
+	if er := t.Delete(key); er != nil {
+		logger.Debugf("[%v] Delete %q: %v", id, key, er)
+	}
+}
+
 func (t *traefik) DeleteFrontend(fr loadbalancer.Frontend) error {
 	logger.Debugf("[%v] Attempting to delete: %v", fr.GetID(), fr)
 	key := path.Join(t.prefix, "frontends", fr.GetID())
@@ -120,7 +198,11 @@ func (t *traefik) NewBackend(rsc *kubernetes.Resource) (loadbalancer.Backend, er
 		b.CircuitBreaker = &types.CircuitBreaker{Expression: exp}
 	}
 
-	return &backend{Backend: *b, id: rsc.ID()}, nil
+	ba := &backend{Backend: *b, id: rsc.ID()}
+	if rsc.IsSticky() {
+		ba.Sticky, ba.Cookie = true, rsc.StickyCookie()
+	}
+	return ba, nil
 }
 
 func (t *traefik) GetBackend(id string) (loadbalancer.Backend, error) {
@@ -135,17 +217,30 @@ func (t *traefik) UpsertBackend(ba loadbalancer.Backend) error {
 
 	pre := path.Join(t.prefix, "backends", ba.GetID())
 	if b.CircuitBreaker != nil && b.CircuitBreaker.Expression != "" {
-		if er := t.Set(path.Join(pre, cb), b.CircuitBreaker.Expression); er != nil {
+		if er := t.Set(path.Join(pre, cb, "expression"), b.CircuitBreaker.Expression); er != nil {
 			logger.Warnf("[%v] Upsert %s error: %v", ba.GetID(), cb, er)
 		}
 	}
 	if b.LoadBalancer != nil && b.LoadBalancer.Method != "" {
-		if er := t.Set(path.Join(pre, lb), b.LoadBalancer.Method); er != nil {
+		if er := t.Set(path.Join(pre, lb, "method"), b.LoadBalancer.Method); er != nil {
 			logger.Warnf("[%v] Upsert %s error: %v", ba.GetID(), lb, er)
 		}
 	}
+	if er := t.Set(path.Join(pre, lb, sticky), strconv.FormatBool(b.Sticky)); er != nil {
+		logger.Warnf("[%v] Upsert %s error: %v", ba.GetID(), sticky, er)
+	}
+	if b.Sticky {
+		if er := t.Set(path.Join(pre, lb, cookie), b.Cookie); er != nil {
+			logger.Warnf("[%v] Upsert %s error: %v", ba.GetID(), cookie, er)
+		}
+	}
 
+	extra := make(map[string]loadbalancer.Server)
+	for _, srv := range getServers(t.Client, t.prefix, ba.GetID()) {
+		extra[srv.GetID()] = srv
+	}
 	for id, srv := range b.Servers {
+		delete(extra, id)
 		logger.Debugf("[%v] Upserting Server(%v)", ba.GetID(), srv.URL)
 		urlK := path.Join(pre, "servers", id, "url")
 		weightK := path.Join(pre, "servers", id, "weight")
@@ -157,6 +252,12 @@ func (t *traefik) UpsertBackend(ba loadbalancer.Backend) error {
 			logger.Warnf("[%v] Upsert error: %v", ba.GetID(), er)
 		}
 	}
+	for _, srv := range extra {
+		logger.Infof("Removing %v", srv)
+		if er := t.DeleteServer(ba, srv); er != nil {
+			logger.Warnf("[%v] Delete error: %v", ba.GetID(), er)
+		}
+	}
 	return nil
 }
 
@@ -169,7 +270,7 @@ func (t *traefik) DeleteBackend(ba loadbalancer.Backend) error {
 func (t *traefik) NewServers(rsc *kubernetes.Resource) ([]loadbalancer.Server, error) {
 	list := make([]loadbalancer.Server, 0, 1)
 	for _, srv := range rsc.Servers() {
-		s := types.Server{URL: srv.URL().String(), Weight: 1}
+		s := types.Server{URL: srv.URL().String(), Weight: srv.Weight()}
 		list = append(list, &server{Server: s, id: srv.ID()})
 	}
 	return list, nil
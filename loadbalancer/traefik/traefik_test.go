@@ -0,0 +1,104 @@
+package traefik
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/timelinelabs/romulus/kubernetes"
+)
+
+// memKV is an in-memory ezd.Client
+type memKV map[string]string
+
+func (m memKV) Exists(key string) error {
+	_, er := m.Get(key)
+	return er
+}
+
+func (m memKV) Keys(pre string) ([]string, error) {
+	seen := map[string]bool{}
+	keys := []string{}
+	for k := range m {
+		if !strings.HasPrefix(k, pre+"/") {
+			continue
+		}
+		child := path.Join(pre, strings.SplitN(strings.TrimPrefix(k, pre+"/"), "/", 2)[0])
+		if !seen[child] {
+			seen[child] = true
+			keys = append(keys, child)
+		}
+	}
+	return keys, nil
+}
+
+func (m memKV) Mkdir(string) error { return nil }
+
+func (m memKV) Set(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+func (m memKV) Get(key string) (string, error) {
+	if val, ok := m[key]; ok {
+		return val, nil
+	}
+	return "", errors.New("key not found")
+}
+
+func (m memKV) Delete(key string) error {
+	for k := range m {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(m, k)
+		}
+	}
+	return nil
+}
+
+func (m memKV) under(pre string) []string {
+	keys := []string{}
+	for k := range m {
+		if strings.HasPrefix(k, pre) {
+			keys = append(keys, strings.TrimPrefix(k, pre))
+		}
+	}
+	return keys
+}
+
+func TestUpsertFrontendClearsOldKeys(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		kv  = memKV{}
+		t   = &traefik{Client: kv, prefix: DefaultPrefix}
+		pre = DefaultPrefix + "/frontends/test.app.web/"
+	)
+
+	upsert := func(annotations map[string]string) {
+		frs := kubernetes.NewResource("test.app.web", "web", annotations).Frontends()
+		fr, er := t.NewFrontend(frs[len(frs)-1])
+		if is.NoError(er) {
+			is.NoError(t.UpsertFrontend(fr))
+		}
+	}
+
+	upsert(map[string]string{"romulus/priority": "9500"})
+	is.Equal("9500", kv[pre+"priority"])
+	upsert(map[string]string{})
+	is.Equal("1000", kv[pre+"priority"], "a removed priority annotation should give the computed priority")
+
+	kv.Set(pre+"redirect/regex", kubernetes.RedirectExpr)
+	upsert(map[string]string{})
+	is.NotContains(kv.under(pre), "redirect/regex", "a removed redirect should go away")
+
+	is.NoError(t.UpsertFrontend(&frontend{id: "test.app.web"}))
+	is.NotContains(kv.under(pre), "priority", "a frontend without priority should not keep the old one")
+
+	upsert(map[string]string{"romulus/prefix": "/blog", "romulus/strip_prefix": "true", "romulus/rewrite_path": "^/old/(.*) /new/$1"})
+	is.Equal("PathPrefixStrip: /blog", kv[pre+"routes/prefix/rule"])
+	is.Contains(kv.under(pre), "routes/rewrite/rule")
+	upsert(map[string]string{"romulus/prefix": "/blog"})
+	is.Equal("PathPrefix: /blog", kv[pre+"routes/prefix/rule"])
+	is.NotContains(kv.under(pre), "routes/rewrite/rule", "a removed rewrite_path should go away")
+}
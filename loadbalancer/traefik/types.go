@@ -19,11 +19,26 @@ type frontend struct {
 	types.Frontend
 	id          string
 	middlewares []*middleware
+
+	// Priority orders overlapping frontends, the vendored traefik types lack it
+	Priority int `json:"priority,omitempty"`
+	// Redirect answers all requests with a redirect, the vendored traefik types lack it
+	Redirect *redirect `json:"redirect,omitempty"`
+}
+
+type redirect struct {
+	Regex       string `json:"regex"`
+	Replacement string `json:"replacement"`
+	Permanent   bool   `json:"permanent,omitempty"`
 }
 
 type backend struct {
 	types.Backend
 	id string
+
+	// Sticky pins clients to a server with a session cookie, the vendored traefik types lack it
+	Sticky bool   `json:"sticky,omitempty"`
+	Cookie string `json:"cookie,omitempty"`
 }
 
 type server struct {
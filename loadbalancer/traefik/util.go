@@ -31,13 +31,20 @@ func getBackend(s ezd.Client, prefix, id string) (*backend, error) {
 	if cb != "" {
 		b.CircuitBreaker = &types.CircuitBreaker{Expression: cb}
 	}
+	ba := &backend{Backend: *b, id: id}
+	if val, er := s.Get(path.Join(kp, "loadbalancer", "sticky")); er == nil {
+		ba.Sticky, _ = strconv.ParseBool(val)
+	}
+	if val, er := s.Get(path.Join(kp, "loadbalancer", "stickiness", "cookieName")); er == nil {
+		ba.Cookie = val
+	}
 
 	servers, er := s.Keys(path.Join(kp, "servers"))
 	if er != nil {
 		logger.Debugf("[%v] Key read error: %v", er)
-		return &backend{Backend: *b, id: id}, nil
+		return ba, nil
 	}
-	b.Servers = make(map[string]types.Server)
+	ba.Servers = make(map[string]types.Server)
 	for _, server := range servers {
 		srvID := path.Base(server)
 		if srvID == "." || srvID == "/" {
@@ -54,9 +61,9 @@ func getBackend(s ezd.Client, prefix, id string) (*backend, error) {
 			continue
 		}
 		i, _ := strconv.Atoi(w)
-		b.Servers[srvID] = types.Server{URL: u, Weight: i}
+		ba.Servers[srvID] = types.Server{URL: u, Weight: i}
 	}
-	return &backend{Backend: *b, id: id}, nil
+	return ba, nil
 }
 
 func getFrontend(s ezd.Client, prefix, id string) (*frontend, error) {
@@ -75,11 +82,23 @@ func getFrontend(s ezd.Client, prefix, id string) (*frontend, error) {
 	val, _ := strconv.ParseBool(pas)
 	f.Backend = bnd
 	f.PassHostHeader = val
+	pri := 0
+	if p, er := s.Get(path.Join(kp, "priority")); er == nil {
+		pri, _ = strconv.Atoi(p)
+	}
+	var rd *redirect
+	if re, er := s.Get(path.Join(kp, "redirect", "regex")); er == nil && re != "" {
+		rd = &redirect{Regex: re}
+		rd.Replacement, _ = s.Get(path.Join(kp, "redirect", "replacement"))
+		if p, er := s.Get(path.Join(kp, "redirect", "permanent")); er == nil {
+			rd.Permanent, _ = strconv.ParseBool(p)
+		}
+	}
 
 	routes, er := s.Keys(path.Join(kp, "routes"))
 	if er != nil {
 		logger.Debugf("[%v] Key read error: %v", id, er)
-		return &frontend{Frontend: *f, id: id}, nil
+		return &frontend{Frontend: *f, id: id, Priority: pri, Redirect: rd}, nil
 	}
 
 	f.Routes = make(map[string]types.Route)
@@ -100,7 +119,7 @@ func getFrontend(s ezd.Client, prefix, id string) (*frontend, error) {
 		}
 		f.Routes[rtID] = types.Route{Rule: fmt.Sprintf("%s: %s", r, v)}
 	}
-	return &frontend{Frontend: *f, id: id}, nil
+	return &frontend{Frontend: *f, id: id, Priority: pri, Redirect: rd}, nil
 }
 
 func getServers(s ezd.Client, prefix, id string) (list []loadbalancer.Server) {
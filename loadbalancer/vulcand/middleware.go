@@ -10,6 +10,53 @@ var DefaultMiddleware = map[string]string{
         "Rewritebody": false,
         "Redirect": true
       }
+    }`,
+	RedirectID: `{
+      "Priority": 1,
+      "Type": "rewrite",
+      "Middleware": {
+        "Regexp": %q,
+        "Replacement": %q,
+        "Rewritebody": false,
+        "Redirect": true
+      }
+    }`,
+	StripPrefixID: `{
+      "Priority": 2,
+      "Type": "rewrite",
+      "Middleware": {
+        "Regexp": %q,
+        "Replacement": %q,
+        "Rewritebody": false,
+        "Redirect": false
+      }
+    }`,
+	RewritePathID: `{
+      "Priority": 3,
+      "Type": "rewrite",
+      "Middleware": {
+        "Regexp": %q,
+        "Replacement": %q,
+        "Rewritebody": false,
+        "Redirect": false
+      }
+    }`,
+	NoEndpointsID: `{
+      "Priority": 1,
+      "Type": "cbreaker",
+      "Middleware": {
+        "Condition": "LatencyAtQuantileMS(50.0) >= 0",
+        "Fallback": {
+          "Type": "response",
+          "Action": {
+            "StatusCode": %d,
+            "Body": %q
+          }
+        },
+        "FallbackDuration": 86400000000000,
+        "RecoveryDuration": 1000000000,
+        "CheckPeriod": 1000000
+      }
     }`,
 	TraceID: `{
       "Priority": 1,
@@ -3,6 +3,7 @@ package vulcand
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -23,12 +24,14 @@ const (
 func NewRoute(rt *kubernetes.Route) *route {
 	var (
 		r      = &route{methods: make([]*routePart, 0, 1), headers: make([]*routePart, 0, 1)}
+		hosts  = make([]*routePart, 0, 1)
 		prefix = false
 	)
 
 	if rt.Empty() {
 		return &route{path: &routePart{part: PathRegexPart, val: ".*"}}
 	}
+	r.tier = kubernetes.PriorityTier(rt.Priority())
 
 	for _, part := range rt.Parts() {
 		rp := &routePart{val: part.Value()}
@@ -40,7 +43,8 @@ func NewRoute(rt *kubernetes.Route) *route {
 			} else {
 				rp.part = HostPart
 			}
-			r.host = rp
+			rp.wildcard = part.IsWildcard()
+			hosts = append(hosts, rp)
 		case kubernetes.MethodPart:
 			if part.IsRegex() {
 				rp.part = MethodRegexPart
@@ -56,6 +60,12 @@ func NewRoute(rt *kubernetes.Route) *route {
 			}
 			rp.header = part.Header()
 			r.headers = append(r.headers, rp)
+		case kubernetes.CookiePart:
+			// Matched on the Cookie header
+			rp.part = HeaderRegexPart
+			rp.header = "Cookie"
+			rp.val = part.CookieRegexp()
+			r.headers = append(r.headers, rp)
 		case kubernetes.PathPart:
 			if part.IsRegex() {
 				rp.part = PathRegexPart
@@ -67,15 +77,58 @@ func NewRoute(rt *kubernetes.Route) *route {
 			}
 		case kubernetes.PrefixPart:
 			rp.part = PathRegexPart
-			rp.val = fmt.Sprintf("%s.*", part.Value())
+			rp.val = prefixRegexp(part.Value(), part.IsRegex())
 			r.path = rp
 			prefix = true
 		}
 	}
 
+	switch {
+	case len(hosts) == 1 && !hosts[0].wildcard:
+		r.host = hosts[0]
+	case len(hosts) > 0:
+		r.host = hostAlternatives(hosts)
+	}
 	return r
 }
 
+// prefixRegexp matches paths under prefix. Plain prefixes end at a path segment,
+// /api matches /api and /api/v1 but not /apiary.
+func prefixRegexp(prefix string, regex bool) string {
+	switch {
+	case regex:
+		return fmt.Sprintf("%s.*", prefix)
+	case strings.HasSuffix(prefix, "/"):
+		return fmt.Sprintf("^%s.*", regexp.QuoteMeta(prefix))
+	}
+	return fmt.Sprintf("^%s(/.*)?$", regexp.QuoteMeta(prefix))
+}
+
+// hostAlternatives folds several hosts into one HostRegexp, vulcand routes have no OR.
+func hostAlternatives(hosts []*routePart) *routePart {
+	var (
+		alts     = make([]string, 0, len(hosts))
+		wildcard = false
+	)
+	for _, h := range hosts {
+		switch {
+		case h.wildcard:
+			alts = append(alts, `[^.]+`+regexp.QuoteMeta(strings.TrimPrefix(h.val, "*")))
+			wildcard = true
+		case h.part == HostRegexPart:
+			alts = append(alts, fmt.Sprintf("(?:%s)", h.val))
+		default:
+			alts = append(alts, regexp.QuoteMeta(h.val))
+		}
+	}
+	sort.Strings(alts)
+	return &routePart{
+		part:     HostRegexPart,
+		val:      fmt.Sprintf("^(%s)$", strings.Join(alts, "|")),
+		wildcard: wildcard,
+	}
+}
+
 func NewRouteFromString(expr string) *route {
 	var (
 		r       = &route{methods: make([]*routePart, 0, 1), headers: make([]*routePart, 0, 1)}
@@ -118,7 +171,13 @@ func (r *route) String() string {
 		parts = append(parts, header.String())
 	}
 
-	return strings.Join(parts, " && ")
+	// vulcand tries routes in reverse lexical order. Every tier below the top one wraps
+	// the expression in one more pair of parens, which sorts it after the higher tiers.
+	depth := kubernetes.PriorityTiers - 1 - r.tier
+	if depth < 0 {
+		depth = 0
+	}
+	return strings.Repeat("(", depth) + strings.Join(parts, " && ") + strings.Repeat(")", depth)
 }
 
 func (r *routePart) String() string {
@@ -138,3 +197,14 @@ func (r *routePart) String() string {
 
 	return fmt.Sprintf("%s(%s)", r.part, val)
 }
+
+// unsupportedParts returns the route parts vulcand can not match on
+func unsupportedParts(rt *kubernetes.Route) []string {
+	list := make([]string, 0, 1)
+	for _, part := range rt.Parts() {
+		if part.Type() == kubernetes.QueryPart {
+			list = append(list, part.String())
+		}
+	}
+	return list
+}
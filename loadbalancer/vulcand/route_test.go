@@ -7,6 +7,7 @@ import (
 	"github.com/albertrdixon/gearbox/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/timelinelabs/romulus/kubernetes"
+	"github.com/timelinelabs/romulus/loadbalancer"
 	vroute "github.com/vulcand/route"
 )
 
@@ -17,17 +18,25 @@ func TestBuildRoute(te *testing.T) {
 			expected    string
 			annotations map[string]string
 		}{
-			{"PathRegexp(`.*`)", map[string]string{}},
-			{"Host(`abc`) && PathRegexp(`/f.*`)", map[string]string{"host": "abc", "prefix": "/f"}},
-			{"Method(`GET`) && Method(`POST`)", map[string]string{"methods": "get; post"}},
+			{"((((((((PathRegexp(`.*`)))))))))", map[string]string{}},
+			{"(Host(`abc`) && PathRegexp(`^/f(/.*)?$`))", map[string]string{"host": "abc", "prefix": "/f"}},
+			{"((((((((Method(`GET`) && Method(`POST`)))))))))", map[string]string{"methods": "get; post"}},
 			{
-				"Header(`X-Foo`, `Bar`) && HeaderRegexp(`X-Bif`, `Baz.*`)",
+				"((((((((Header(`X-Foo`, `Bar`) && HeaderRegexp(`X-Bif`, `Baz.*`)))))))))",
 				map[string]string{"headers": "X-Foo=Bar; X-Bif=|Baz.*|"},
 			},
 			{
-				"HostRegexp(`.*local`) && PathRegexp(`/f/b.*`)",
+				"((((HostRegexp(`.*local`) && PathRegexp(`/f/b.*`)))))",
 				map[string]string{"host": "|.*local|", "path": "|/f/b.*|"},
 			},
+			{
+				"(((((HostRegexp(`^((?:.*local)|a\\.com)$`))))))",
+				map[string]string{"hosts": "a.com, |.*local|"},
+			},
+			{
+				"((((HostRegexp(`^([^.]+\\.example\\.com)$`) && PathRegexp(`^/f(/.*)?$`)))))",
+				map[string]string{"host": "*.example.com", "prefix": "/f"},
+			},
 		}
 	)
 
@@ -43,3 +52,60 @@ func TestBuildRoute(te *testing.T) {
 		is.True(vroute.IsValid(actual))
 	}
 }
+
+func TestQueryAndCookieRoute(te *testing.T) {
+	var (
+		is = assert.New(te)
+		rt = kubernetes.NewRoute("foo", map[string]string{"cookies": "canary=|yes|on|", "query": "v=2"})
+	)
+
+	actual := NewRoute(rt).String()
+	is.Equal("((((((((HeaderRegexp(`Cookie`, `(^|;\\s*)canary=(?:yes|on)(;|$)`)))))))))", actual)
+	is.True(vroute.IsValid(actual))
+	is.Equal([]string{"query(`v`, `2`)"}, unsupportedParts(rt))
+
+	_, er := new(vulcan).NewFrontend(kubernetes.NewResource("test.foo.web", "web", map[string]string{"romulus/query": "v=2"}))
+	if is.IsType(&loadbalancer.UnsupportedRouteError{}, er, "a route without its query matcher would be wider") {
+		is.Equal([]string{"query(`v`, `2`)"}, er.(*loadbalancer.UnsupportedRouteError).Parts)
+	}
+}
+
+func TestWildcardHostPrecedence(te *testing.T) {
+	var (
+		is       = assert.New(te)
+		exact    = NewRoute(kubernetes.NewRoute("foo", map[string]string{"host": "www.example.com", "path": "/"}))
+		wildcard = NewRoute(kubernetes.NewRoute("bar", map[string]string{"host": "*.example.com", "path": "/"}))
+	)
+
+	// vulcand tries routes in reverse lexical order
+	is.True(exact.String() > wildcard.String(), "%v should be tried before %v", exact, wildcard)
+}
+
+func TestRoutePrecedence(te *testing.T) {
+	var (
+		is     = assert.New(te)
+		routes = []map[string]string{
+			{"host": "www.example.com", "path": "/api"},
+			{"host": "www.example.com", "prefix": "/api/v2"},
+			{"host": "www.example.com", "prefix": "/api"},
+			{"host": "www.example.com"},
+			{"host": "*.example.com", "prefix": "/api"},
+			{"path": "/api"},
+			{"prefix": "/api"},
+			{},
+		}
+	)
+
+	// vulcand tries routes in reverse lexical order
+	for i := 1; i < len(routes); i++ {
+		first := NewRoute(kubernetes.NewRoute("foo", routes[i-1])).String()
+		then := NewRoute(kubernetes.NewRoute("bar", routes[i])).String()
+		is.True(first > then, "%s should be tried before %s", first, then)
+	}
+
+	rsc := kubernetes.NewResource("test.foo.web", "web", map[string]string{"romulus/priority": "9000"})
+	fr, er := new(vulcan).NewFrontend(rsc)
+	if is.NoError(er) {
+		is.Equal("PathRegexp(`.*`)", fr.(*frontend).Route, "the priority annotation should move the route to the top tier")
+	}
+}
@@ -54,8 +54,10 @@ func newMiddleware(m *engine.Middleware) *middleware {
 type route struct {
 	host, path       *routePart
 	headers, methods []*routePart
+	tier             int
 }
 
 type routePart struct {
 	part, val, header string
+	wildcard          bool
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"regexp/syntax"
 	"strconv"
 	"strings"
 	"time"
@@ -41,6 +42,16 @@ const (
 	TraceID       = "trace"
 	AuthID        = "auth"
 	MaintenanceID = "maintenance"
+	StripPrefixID = kubernetes.StripPrefixKey
+	RewritePathID = kubernetes.RewritePathKey
+	RedirectID    = kubernetes.RedirectToKey
+	NoEndpointsID = kubernetes.NoEndpointsKey
+
+	// MaxServerWeight caps the copies of a server standing in for its weight
+	MaxServerWeight = 100
+
+	// urlHostExpr matches the scheme and host the rewrite middleware sees in front of the path
+	urlHostExpr = `^https?://[^/]+`
 )
 
 func New(vulcanURL string, reg *plugin.Registry, ctx context.Context) (*vulcan, error) {
@@ -81,7 +92,16 @@ func (v *vulcan) NewFrontend(rsc *kubernetes.Resource) (loadbalancer.Frontend, e
 		rt = NewRoute(rsc.Route)
 	)
 
-	if rt.host != nil && rt.host.val != "" {
+	if parts := unsupportedParts(rsc.Route); len(parts) > 0 {
+		return nil, &loadbalancer.UnsupportedRouteError{
+			Frontend: rsc.FrontendID(),
+			Route:    rsc.Route.String(),
+			Reason:   "vulcand does not support query matchers",
+			Parts:    parts,
+		}
+	}
+	rt.tier = kubernetes.PriorityTier(rsc.Priority())
+	if rt.host != nil && rt.host.part == HostPart && rt.host.val != "" {
 		s.Hostname = rt.host.val
 	}
 	if val, ok := rsc.GetAnnotation(loadbalancer.PassHostHeaderKey); ok {
@@ -120,7 +140,7 @@ func (v *vulcan) NewFrontend(rsc *kubernetes.Resource) (loadbalancer.Frontend, e
 		}
 	}
 
-	f, er := engine.NewHTTPFrontend(vroute.NewMux(), rsc.ID(), rsc.ID(), rt.String(), s)
+	f, er := engine.NewHTTPFrontend(vroute.NewMux(), rsc.FrontendID(), rsc.ID(), rt.String(), s)
 	if er != nil {
 		return nil, er
 	}
@@ -164,23 +184,86 @@ func (v *vulcan) NewBackend(rsc *kubernetes.Resource) (loadbalancer.Backend, err
 	if rsc.IsWebsocket() {
 		b.Type = ws
 	}
+	if rsc.IsSticky() {
+		logger.Warnf("[%v] vulcand does not support sticky sessions, requests are balanced across servers", rsc.ID())
+	}
 	return newBackend(b), nil
 }
 
+// NewServers returns the servers of rsc. vulcand servers have no weight, so zero weight servers are
+// left out and a server of weight N is added N times, the copies told apart by a URL path the
+// proxy does not forward. Weights above MaxServerWeight scale all servers of rsc down together.
 func (v *vulcan) NewServers(rsc *kubernetes.Resource) ([]loadbalancer.Server, error) {
-	list := make([]loadbalancer.Server, 0, 1)
-	for _, server := range rsc.Servers() {
-		s, er := engine.NewServer(server.ID(), server.URL().String())
-		if er != nil {
-			return list, er
+	var (
+		list    = make([]loadbalancer.Server, 0, 1)
+		servers = rsc.Servers()
+		weights = serverWeights(rsc.ID(), servers)
+	)
+	for i, server := range servers {
+		for n := 1; n <= weights[i]; n++ {
+			id, u := server.ID(), server.URL()
+			if n > 1 {
+				id = fmt.Sprintf("%s.%d", id, n)
+				u.Path = fmt.Sprintf("/%d", n)
+			}
+			s, er := engine.NewServer(id, u.String())
+			if er != nil {
+				return list, er
+			}
+			list = append(list, newServer(s))
 		}
-		list = append(list, newServer(s))
 	}
 	return list, nil
 }
 
+// serverWeights returns the weights of servers, scaled together so none is above MaxServerWeight
+func serverWeights(id string, servers kubernetes.ServerList) []int {
+	var (
+		top     = 0
+		weights = make([]int, len(servers))
+	)
+	for i, server := range servers {
+		if weights[i] = server.Weight(); weights[i] > top {
+			top = weights[i]
+		}
+	}
+	if top <= MaxServerWeight {
+		return weights
+	}
+
+	var (
+		exact  = make([]float64, len(servers))
+		counts = make([]int, len(servers))
+	)
+	for i, w := range weights {
+		exact[i], counts[i] = float64(w), 1
+	}
+	fitted, drift := kubernetes.FitWeights(exact, counts, MaxServerWeight)
+	if drift > kubernetes.WeightTolerance {
+		logger.Warnf("[%v] vulcand can not represent weights %v with copies of servers, %v is off by up to %.1f%% of the traffic",
+			id, weights, fitted, drift*100)
+	} else {
+		logger.Debugf("[%v] Scaled vulcand weights %v down to %v", id, weights, fitted)
+	}
+	return fitted
+}
+
 func (v *vulcan) NewMiddlewares(rsc *kubernetes.Resource) ([]loadbalancer.Middleware, error) {
 	mids := make([]loadbalancer.Middleware, 0, 1)
+	if rd, ok := rsc.Redirect(); ok {
+		// vulcand always answers 302, only warn when 301 was asked for rather than defaulted to
+		if _, set := rsc.GetAnnotation(kubernetes.RedirectCodeKey); set && rd.Permanent() {
+			logger.Warnf("[%v] vulcand redirects with 302 Found, not %d", rsc.FrontendID(), rd.Code)
+		}
+		// the redirect frontend never reaches the servers, other middlewares do not apply
+		def := fmt.Sprintf(DefaultMiddleware[RedirectID], kubernetes.RedirectExpr, rd.Replacement())
+		m, er := engine.MiddlewareFromJSON([]byte(def), v.Registry.GetSpec, RedirectID)
+		if er != nil {
+			return mids, er
+		}
+		return append(mids, newMiddleware(m)), nil
+	}
+
 	for key, def := range DefaultMiddleware {
 		if val, ok := rsc.GetAnnotation(key); ok && len(val) > 0 {
 			switch key {
@@ -209,6 +292,31 @@ func (v *vulcan) NewMiddlewares(rsc *kubernetes.Resource) ([]loadbalancer.Middle
 				}
 			case MaintenanceID:
 				def = fmt.Sprintf(def, val)
+			case RedirectID:
+				continue
+			case NoEndpointsID:
+				resp, ok := rsc.NoEndpointsResponse()
+				if !ok {
+					continue
+				}
+				def = fmt.Sprintf(def, resp.Code, resp.Body)
+			case StripPrefixID:
+				pre, ok := rsc.StripPrefix()
+				if !ok {
+					continue
+				}
+				def = fmt.Sprintf(def, urlHostExpr+regexp.QuoteMeta(pre)+"/?", "/")
+			case RewritePathID:
+				expr, repl, ok := rsc.RewritePath()
+				if !ok {
+					continue
+				}
+				full, er := urlPathExpr(expr)
+				if er != nil {
+					logger.Warnf("[%v] vulcand can not rewrite paths with %s: %v", rsc.ID(), kubernetes.RewritePathKey, er)
+					continue
+				}
+				def = fmt.Sprintf(def, full, repl)
 			}
 
 			m, er := engine.MiddlewareFromJSON([]byte(def), v.Registry.GetSpec, key)
@@ -246,10 +354,26 @@ func (v *vulcan) UpsertFrontend(fr loadbalancer.Frontend) error {
 	if er := v.Client.UpsertFrontend(f.Frontend, 0); er != nil {
 		return er
 	}
+
+	// remove default middlewares no longer set, e.g. the no endpoints response once servers are back
+	extra := make(map[string]bool)
+	ms, _ := v.Client.GetMiddlewares(f.GetKey())
+	for i := range ms {
+		if _, ok := DefaultMiddleware[ms[i].Id]; ok {
+			extra[ms[i].Id] = true
+		}
+	}
 	for _, mid := range f.middlewares {
 		if er := v.UpsertMiddleware(f.GetKey(), mid.Middleware, 0); er != nil {
 			logger.Warnf("Failed to upsert Middleware %s for frontend %s: %v", mid.GetID(), f.GetID(), er)
 		}
+		delete(extra, mid.GetID())
+	}
+	for id := range extra {
+		logger.Infof("Removing Middleware %s of %v", id, f)
+		if er := v.DeleteMiddleware(engine.MiddlewareKey{FrontendKey: f.GetKey(), Id: id}); er != nil {
+			logger.Warnf("Failed to delete Middleware %s for frontend %s: %v", id, f.GetID(), er)
+		}
 	}
 	return nil
 }
@@ -295,6 +419,18 @@ func (v *vulcan) GetFrontend(frontendID string) (loadbalancer.Frontend, error) {
 	return newFrontend(f), nil
 }
 
+func (v *vulcan) ListFrontends() ([]string, error) {
+	list, er := v.Client.GetFrontends()
+	if er != nil {
+		return nil, er
+	}
+	ids := make([]string, 0, len(list))
+	for _, f := range list {
+		ids = append(ids, f.Id)
+	}
+	return ids, nil
+}
+
 func (v *vulcan) GetBackend(backendID string) (loadbalancer.Backend, error) {
 	logger.Debugf("Lookup Backend: %q", backendID)
 	b, er := v.Client.GetBackend(engine.BackendKey{Id: backendID})
@@ -358,3 +494,38 @@ func (f *frontend) GetRoute() string {
 func (m *middleware) GetID() string { return m.Id }
 
 func (s *server) GetID() string { return s.GetId() }
+
+// urlPathExpr turns a regexp anchored at the start of the path into one matching the URL the
+// rewrite middleware sees. Every alternative has to be anchored, vulcand rewrites the whole URL
+// and can not replace a part of the path like traefik.
+func urlPathExpr(expr string) (string, error) {
+	re, er := syntax.Parse(expr, syntax.Perl)
+	if er != nil {
+		return "", er
+	}
+	if !unanchor(re) {
+		return "", fmt.Errorf("%q should start with ^/ in every alternative", expr)
+	}
+	return urlHostExpr + "(?:" + re.String() + ")", nil
+}
+
+// unanchor removes the leading ^ of re and every alternative in it, false if one lacks it
+func unanchor(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginText:
+		re.Op = syntax.OpEmptyMatch
+		return true
+	case syntax.OpConcat:
+		return len(re.Sub) > 0 && unanchor(re.Sub[0])
+	case syntax.OpCapture:
+		return unanchor(re.Sub[0])
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !unanchor(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
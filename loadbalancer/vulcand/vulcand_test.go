@@ -1,13 +1,17 @@
 package vulcand
 
 import (
+	"fmt"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/albertrdixon/gearbox/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/timelinelabs/romulus/kubernetes"
 	"github.com/timelinelabs/vulcand/api"
+	"github.com/timelinelabs/vulcand/plugin/cbreaker"
 	"github.com/timelinelabs/vulcand/plugin/registry"
 )
 
@@ -151,6 +155,28 @@ func TestNewMiddleware(te *testing.T) {
 				is.Equal(MaintenanceID, m.GetID())
 				is.Equal("cbreaker", m.Type)
 			}},
+			{kubernetes.NewResource("strip", "", map[string]string{
+				"romulus/prefix":           "/blog",
+				"romulus/" + StripPrefixID: "true",
+			}), func(m *middleware) {
+				is.Equal(StripPrefixID, m.GetID())
+				is.Equal("rewrite", m.Type)
+			}},
+			{kubernetes.NewResource("rewrite_path", "", map[string]string{
+				"romulus/" + RewritePathID: "^/v1/(.*) /api/$1",
+			}), func(m *middleware) {
+				is.Equal(RewritePathID, m.GetID())
+				is.Equal("rewrite", m.Type)
+			}},
+			{kubernetes.NewResource("no_endpoints", "", map[string]string{
+				"romulus/" + NoEndpointsID: "503 Down for maintenance",
+			}), func(m *middleware) {
+				is.Equal(NoEndpointsID, m.GetID())
+				is.Equal("cbreaker", m.Type)
+				if spec, ok := m.Middleware.Middleware.(*cbreaker.Spec); is.True(ok) {
+					is.Contains(fmt.Sprint(spec.Fallback), "Down for maintenance")
+				}
+			}},
 			{kubernetes.NewResource("custom", "", map[string]string{
 				"romulus/middleware.foo": `{"Type":"ratelimit","Middleware":{"Requests":1,"PeriodSeconds":1,"Burst":3,"Variable":"client.ip"}}`,
 			}), func(m *middleware) {
@@ -168,3 +194,128 @@ func TestNewMiddleware(te *testing.T) {
 		}
 	}
 }
+
+func TestRedirectMiddleware(te *testing.T) {
+	var (
+		is     = assert.New(te)
+		client = api.NewClient("localhost", registry.GetRegistry())
+		v      = &vulcan{Client: *client}
+		rsc    = kubernetes.NewResource("www", "", map[string]string{
+			"host":          "example.com",
+			"redirect_host": "www.example.com",
+			"redirect_to":   "example.com",
+			"auth":          "username:password",
+		})
+		frontends = rsc.Frontends()
+	)
+
+	if !is.Len(frontends, 2) {
+		return
+	}
+	mids, er := v.NewMiddlewares(frontends[0])
+	if is.NoError(er) && is.Len(mids, 1, "the own frontend keeps its middlewares") {
+		is.Equal(AuthID, mids[0].GetID())
+	}
+
+	mids, er = v.NewMiddlewares(frontends[1])
+	if is.NoError(er) && is.Len(mids, 1, "the redirect frontend only redirects") {
+		m := mids[0].(*middleware)
+		is.Equal(RedirectID, m.GetID())
+		is.Equal("rewrite", m.Type)
+		is.Contains(fmt.Sprint(m.Middleware.Middleware), "redirect=true")
+	}
+	fr, er := v.NewFrontend(frontends[1])
+	if is.NoError(er) {
+		is.Equal("www.redirect", fr.GetID())
+		is.Equal("((Host(`www.example.com`)))", fr.(*frontend).Route)
+	}
+}
+
+func TestNewServersWeights(te *testing.T) {
+	var (
+		is  = assert.New(te)
+		v   = new(vulcan)
+		rsc = kubernetes.NewResource("weights", "", nil)
+	)
+
+	rsc.AddWeightedServer("stable", "http", "10.0.0.1", 80, 3)
+	rsc.AddWeightedServer("canary", "http", "10.0.0.2", 80, 1)
+	rsc.AddWeightedServer("draining", "http", "10.0.0.3", 80, 0)
+
+	list, er := v.NewServers(rsc)
+	if is.NoError(er) && is.Len(list, 4) {
+		urls := make([]string, 0, 4)
+		for _, srv := range list[:4] {
+			urls = append(urls, srv.GetID()+" "+srv.(*server).URL)
+		}
+		is.Equal([]string{
+			"stable http://10.0.0.1:80",
+			"stable.2 http://10.0.0.1:80/2",
+			"stable.3 http://10.0.0.1:80/3",
+			"canary http://10.0.0.2:80",
+		}, urls)
+	}
+
+	// weights over the cap are scaled down together
+	rsc = kubernetes.NewResource("split", "", nil)
+	rsc.AddWeightedServer("stable", "http", "10.0.0.1", 80, 300)
+	rsc.AddWeightedServer("canary", "http", "10.0.0.2", 80, 100)
+	list, er = v.NewServers(rsc)
+	if is.NoError(er) && is.Len(list, 4) {
+		is.Equal("stable.3", list[2].GetID())
+		is.Equal("canary", list[3].GetID())
+	}
+
+	copies := func(rsc *kubernetes.Resource) map[string]int {
+		count := map[string]int{}
+		list, er := v.NewServers(rsc)
+		is.NoError(er)
+		for _, srv := range list {
+			count[strings.SplitN(srv.GetID(), ".", 2)[0]]++
+		}
+		return count
+	}
+
+	rsc = kubernetes.NewResource("heavy", "", nil)
+	rsc.AddWeightedServer("heavy", "http", "10.0.0.1", 80, MaxServerWeight*9)
+	rsc.AddWeightedServer("light", "http", "10.0.0.2", 80, MaxServerWeight)
+	rsc.AddWeightedServer("tiny", "http", "10.0.0.3", 80, MaxServerWeight/2)
+	is.Equal(map[string]int{"heavy": 18, "light": 2, "tiny": 1}, copies(rsc))
+
+	// a split too uneven for the cap keeps every server, with the heaviest at the cap
+	rsc = kubernetes.NewResource("uneven", "", nil)
+	rsc.AddWeightedServer("heavy", "http", "10.0.0.1", 80, MaxServerWeight*9)
+	rsc.AddWeightedServer("light", "http", "10.0.0.2", 80, MaxServerWeight)
+	rsc.AddWeightedServer("tiny", "http", "10.0.0.3", 80, 1)
+	is.Equal(map[string]int{"heavy": MaxServerWeight, "light": 11, "tiny": 1}, copies(rsc))
+}
+
+func TestURLPathExpr(te *testing.T) {
+	var (
+		is    = assert.New(te)
+		tests = []struct {
+			expr, repl string
+			url, to    string
+		}{
+			{"^/v1/(.*)", "/api/$1", "http://example.com/v1/users", "/api/users"},
+			{"^/a|^/b", "/c", "http://example.com/b", "/c"},
+			{"^/a(/.*)|^/b(/.*)", "/c$1$2", "https://example.com/b/x", "/c/x"},
+			{"(^/old)/(.*)", "/new/$2", "http://example.com/old/x", "/new/x"},
+		}
+	)
+
+	for _, t := range tests {
+		full, er := urlPathExpr(t.expr)
+		if !is.NoError(er, t.expr) {
+			continue
+		}
+		re := regexp.MustCompile(full)
+		is.Equal(t.to, re.ReplaceAllString(t.url, t.repl), "%s -> %s", t.expr, full)
+		is.False(re.MatchString(strings.Replace(t.url, "example.com", "example.com/x", 1)), "%s should stay anchored at the path", full)
+	}
+
+	for _, expr := range []string{"v1", "^/a|/b", "(", "/x^"} {
+		_, er := urlPathExpr(expr)
+		is.Error(er, expr)
+	}
+}
@@ -19,7 +19,8 @@ import (
 )
 
 var (
-	lbs = []string{"vulcand", "traefik"}
+	lbs     = []string{"vulcand", "traefik"}
+	sources = []string{"kubernetes", "file"}
 
 	ro = kingpin.New("romulusd", "A kubernetes ingress controller")
 
@@ -28,9 +29,20 @@ var (
 	kubeUser    = ro.Flag("kube-user", "kubernetes username").String()
 	kubePass    = ro.Flag("kube-pass", "kubernetes password").String()
 	kubeSec     = ro.Flag("kube-insecure", "Run kubernetes client in insecure mode").OverrideDefaultFromEnvar("KUBE_INSECURE").Bool()
+	source      = ro.Flag("source", "Object source. One of: kubernetes, file").Default("kubernetes").OverrideDefaultFromEnvar("ROMULUS_SOURCE").Enum(sources...)
+	sourceDir   = ro.Flag("source-dir", "Directory of kubernetes manifests for the file source").OverrideDefaultFromEnvar("ROMULUS_SOURCE_DIR").String()
+	sourcePoll  = ro.Flag("source-poll", "Poll interval for the file source").Default("5s").Duration()
 	selector    = ro.Flag("selector", "label selectors. Leave blank for Everything(). Form: key=value").Short('s').PlaceHolder("label=value").OverrideDefaultFromEnvar("SVC_SELECTOR").StringMap()
 	annoKey     = ro.Flag("annotations-prefix", "annotations key prefix").Short('a').Default("romulus/").String()
 	provider    = ro.Flag("provider", "LoadBalancer provider").Short('p').Default("vulcand").Enum(lbs...)
+	fallback    = ro.Flag("service-fallback", "Fall back to the Service IP when a Service has no Endpoints").Default("true").OverrideDefaultFromEnvar("SERVICE_FALLBACK").Bool()
+	backendMode = ro.Flag("backend-mode", "Build servers from Endpoints addresses or from Node addresses and Service node ports").Default(kubernetes.EndpointsMode).OverrideDefaultFromEnvar("BACKEND_MODE").Enum(kubernetes.EndpointsMode, kubernetes.NodePortMode)
+	configMap   = ro.Flag("config-map", "ConfigMap (namespace/name) with global annotation defaults and overrides").PlaceHolder("namespace/name").OverrideDefaultFromEnvar("ROMULUS_CONFIG_MAP").String()
+	podRoutes   = ro.Flag("pod-routes", "Watch Pods and route to annotated Pods without a Service").OverrideDefaultFromEnvar("POD_ROUTES").Bool()
+	conflicts   = ro.Flag("conflict-policy", "Policy for routes of different namespaces claiming the same requests. One of: oldest, namespace-priority, reject-both").Default(kubernetes.OldestWins).OverrideDefaultFromEnvar("CONFLICT_POLICY").Enum(kubernetes.OldestWins, kubernetes.NamespacePriority, kubernetes.RejectBoth)
+	nsPriority  = ro.Flag("namespace-priority", "Namespaces winning route conflicts under the namespace-priority policy, first wins").PlaceHolder("namespace").Strings()
+	hostPolicy  = ro.Flag("host-policy", "File of host patterns and the namespaces allowed to route them").PlaceHolder("FILE").OverrideDefaultFromEnvar("HOST_POLICY").String()
+	debugAddr   = ro.Flag("debug-addr", "Address to serve the debug API on, disabled if empty").PlaceHolder(":8081").OverrideDefaultFromEnvar("DEBUG_ADDR").String()
 	resync      = ro.Flag("sync-interval", "Resync period with kube api").Default("1h").Duration()
 	timeout     = ro.Flag("lb-timeout", "Timeout for communicating with loadbalancer provider").Default("10s").Duration()
 	vulcanAPI   = ro.Flag("vulcand-api", "URL for vulcand api").Default("http://127.0.0.1:8182").OverrideDefaultFromEnvar("VULCAND_API").URL()
@@ -52,11 +64,33 @@ func main() {
 	}
 
 	kubernetes.Keyspace = normalizeAnnotationsKey(*annoKey)
-	ng, er := NewEngine((*kubeAPI).String(), *kubeUser, *kubePass, *kubeSec, lb, *timeout, ctx)
+	kubernetes.ServiceFallback = *fallback
+	kubernetes.BackendMode = *backendMode
+	kubernetes.PodRoutes = *podRoutes
+	kubernetes.ConflictPolicy = *conflicts
+	kubernetes.NamespacePriorities = *nsPriority
+	if *hostPolicy != "" {
+		rules, er := kubernetes.LoadHostPolicy(*hostPolicy)
+		if er != nil {
+			logger.Fatalf(er.Error())
+		}
+		kubernetes.HostPolicyRules = rules
+	}
+	if *configMap != "" {
+		if _, _, er := kubernetes.ParseConfigMap(*configMap); er != nil {
+			logger.Fatalf(er.Error())
+		}
+		kubernetes.ConfigMap = *configMap
+	}
+	src, er := getSource(*source)
 	if er != nil {
 		logger.Fatalf(er.Error())
 	}
 
+	ng := NewEngine(src, lb, *timeout, ctx)
+	if *debugAddr != "" {
+		go serveDebug(*debugAddr, ng)
+	}
 	if er := ng.Start(*selector, *resync); er != nil {
 		logger.Fatalf(er.Error())
 	}
@@ -87,6 +121,27 @@ func getLBProvider(kind string, c context.Context) (loadbalancer.LoadBalancer, e
 	}
 }
 
+func getSource(kind string) (kubernetes.Source, error) {
+	switch kind {
+	default:
+		return nil, errors.New("Unknown source type")
+	case "kubernetes":
+		kc, er := kubernetes.NewClient((*kubeAPI).String(), *kubeVer, *kubeUser, *kubePass, *kubeSec)
+		if er != nil {
+			return nil, er
+		}
+		return kubernetes.NewAPISource(kc), nil
+	case "file":
+		if *sourceDir == "" {
+			return nil, errors.New("--source-dir is required for the file source")
+		}
+		if *sourcePoll <= 0 {
+			return nil, errors.New("--source-poll must be a positive duration")
+		}
+		return kubernetes.NewFileSource(*sourceDir, *sourcePoll), nil
+	}
+}
+
 func normalizeAnnotationsKey(key string) string {
 	if !strings.HasSuffix(key, "/") {
 		return key + "/"